@@ -0,0 +1,106 @@
+package vsphere
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+var (
+	alarmLabels = []string{"entity_type", "entity", "alarm", "status"}
+
+	alarmTriggeredDesc = prometheus.NewDesc("vsphere_alarm_triggered",
+		"Alarm triggered on a managed entity.", alarmLabels, nil)
+	alarmAcknowledgedDesc = prometheus.NewDesc("vsphere_alarm_acknowledged",
+		"Whether the triggered alarm has been acknowledged.", alarmLabels, nil)
+	alarmTimestampDesc = prometheus.NewDesc("vsphere_alarm_triggered_timestamp_seconds",
+		"Time the alarm was triggered in seconds since the Unix epoch.", alarmLabels, nil)
+)
+
+// collectAlarms sends the alarms triggered anywhere in the inventory. The triggered alarm state of the root folder
+// includes the alarms of all its descendants.
+func (c *vsphereCollector) collectAlarms(ctx context.Context, metrics chan<- prometheus.Metric, cli *client) {
+	ctx1, cancel1 := context.WithTimeout(ctx, cli.Timeout)
+	defer cancel1()
+
+	pc := property.DefaultCollector(cli.Client.Client)
+	var root mo.Folder
+	if err := pc.RetrieveOne(ctx1, cli.Client.ServiceContent.RootFolder, []string{"triggeredAlarmState"}, &root); err != nil {
+		c.logger.Error("error getting triggered alarms", "err", err)
+		return
+	}
+	if len(root.TriggeredAlarmState) == 0 {
+		return
+	}
+
+	var alarmRefs, entityRefs []types.ManagedObjectReference
+	seen := make(map[types.ManagedObjectReference]bool)
+	for _, s := range root.TriggeredAlarmState {
+		if !seen[s.Alarm] {
+			seen[s.Alarm] = true
+			alarmRefs = append(alarmRefs, s.Alarm)
+		}
+		if !seen[s.Entity] {
+			seen[s.Entity] = true
+			entityRefs = append(entityRefs, s.Entity)
+		}
+	}
+
+	// Names that can't be resolved, e.g. because the alarm was just removed, fall back to the moid.
+	alarmNames := make(map[string]string, len(alarmRefs))
+	var alarms []mo.Alarm
+	if err := pc.Retrieve(ctx1, alarmRefs, []string{"info.name"}, &alarms); err != nil {
+		c.logger.Debug("error getting alarm names", "err", err)
+	}
+	for _, a := range alarms {
+		alarmNames[a.Self.Value] = a.Info.Name
+	}
+	entityNames := make(map[string]string, len(entityRefs))
+	var entities []mo.ManagedEntity
+	if err := pc.Retrieve(ctx1, entityRefs, []string{"name"}, &entities); err != nil {
+		c.logger.Debug("error getting alarm entity names", "err", err)
+	}
+	for _, e := range entities {
+		entityNames[e.Self.Value] = e.Name
+	}
+
+	// Alarms triggered on entities of the same kind and name share a series. It's acknowledged once all of them are,
+	// and has the earliest trigger time.
+	type triggered struct {
+		acknowledged bool
+		time         time.Time
+	}
+	states := make(map[[4]string]*triggered)
+	for _, s := range root.TriggeredAlarmState {
+		alarm, ok := alarmNames[s.Alarm.Value]
+		if !ok {
+			alarm = s.Alarm.Value
+		}
+		entity, ok := entityNames[s.Entity.Value]
+		if !ok {
+			entity = s.Entity.Value
+		}
+		key := [4]string{entityKind(&s.Entity), entity, alarm, string(s.OverallStatus)}
+		acknowledged := s.Acknowledged != nil && *s.Acknowledged
+		if t, ok := states[key]; ok {
+			t.acknowledged = t.acknowledged && acknowledged
+			if s.Time.Before(t.time) {
+				t.time = s.Time
+			}
+			continue
+		}
+		states[key] = &triggered{acknowledged: acknowledged, time: s.Time}
+	}
+	for key, t := range states {
+		labels := key[:]
+		metrics <- prometheus.MustNewConstMetric(alarmTriggeredDesc, prometheus.GaugeValue, 1, labels...)
+		metrics <- prometheus.MustNewConstMetric(alarmAcknowledgedDesc, prometheus.GaugeValue,
+			boolToFloat(t.acknowledged), labels...)
+		metrics <- prometheus.MustNewConstMetric(alarmTimestampDesc, prometheus.GaugeValue,
+			float64(t.time.Unix()), labels...)
+	}
+}
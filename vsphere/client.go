@@ -14,6 +14,8 @@ import (
 	"github.com/vmware/govmomi/object"
 	"github.com/vmware/govmomi/performance"
 	"github.com/vmware/govmomi/session"
+	"github.com/vmware/govmomi/vapi/rest"
+	"github.com/vmware/govmomi/vapi/tags"
 	"github.com/vmware/govmomi/view"
 	"github.com/vmware/govmomi/vim25"
 	"github.com/vmware/govmomi/vim25/methods"
@@ -27,6 +29,7 @@ const absoluteMaxMetrics = 10000
 
 type clientFactory struct {
 	client     *client
+	restClient *rest.Client
 	mux        sync.Mutex
 	vSphereURL *url.URL
 	cfg        *vSphereConfig
@@ -92,6 +95,31 @@ func (cf *clientFactory) GetClient(ctx context.Context) (*client, error) {
 	}
 }
 
+// GetTagManager returns a tag manager backed by a vAPI REST session. The session shares the credentials of the SOAP
+// client and is renewed when it expires.
+func (cf *clientFactory) GetTagManager(ctx context.Context) (*tags.Manager, error) {
+	c, err := cf.GetClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cf.mux.Lock()
+	defer cf.mux.Unlock()
+	ctx1, cancel1 := context.WithTimeout(ctx, cf.cfg.Timeout)
+	defer cancel1()
+	if cf.restClient != nil {
+		if s, err := cf.restClient.Session(ctx1); err == nil && s != nil {
+			return tags.NewManager(cf.restClient), nil
+		}
+	}
+	rc := rest.NewClient(c.Client.Client)
+	if err := rc.Login(ctx1, cf.vSphereURL.User); err != nil {
+		return nil, fmt.Errorf("vAPI login failed: %w", err)
+	}
+	cf.restClient = rc
+	return tags.NewManager(rc), nil
+}
+
 // newClient creates a new vSphere client based on the url and setting passed as parameters.
 // TODO: tls config
 func newClient(ctx context.Context, l *slog.Logger, vSphereURL *url.URL, cfg *vSphereConfig) (*client, error) {
@@ -169,6 +197,25 @@ func (c *client) counterInfoByName(ctx context.Context) (map[string]*types.PerfC
 	return c.Perf.CounterInfoByName(ctx1)
 }
 
+// customFieldNames returns the names of the custom fields defined in vCenter keyed by field key
+func (c *client) customFieldNames(ctx context.Context) (map[int32]string, error) {
+	names := make(map[int32]string)
+	if c.Client.ServiceContent.CustomFieldsManager == nil {
+		// Standalone hosts don't support custom fields.
+		return names, nil
+	}
+	ctx1, cancel1 := context.WithTimeout(ctx, c.Timeout)
+	defer cancel1()
+	fields, err := object.NewCustomFieldsManager(c.Client.Client).Field(ctx1)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range fields {
+		names[f.Key] = f.Name
+	}
+	return names, nil
+}
+
 // getServerTime returns the time at the vCenter server
 func (c *client) getServerTime(ctx context.Context) (time.Time, error) {
 	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
@@ -43,11 +43,15 @@ func (c *vsphereCollector) Collect(metrics chan<- prometheus.Metric) {
 	c.endpoint.collectMux.RLock()
 	defer c.endpoint.collectMux.RUnlock()
 
+	before := time.Now()
 	now, err := myClient.getServerTime(ctx)
 	if err != nil {
 		c.logger.Error("failed to get server time", "err", err.Error())
 		return
 	}
+	// Compare the server time to the middle of the round trip.
+	after := time.Now()
+	skew := now.Sub(before.Add(after.Sub(before) / 2))
 
 	var wg sync.WaitGroup
 	for k, r := range c.endpoint.resourceKinds {
@@ -56,10 +60,35 @@ func (c *vsphereCollector) Collect(metrics chan<- prometheus.Metric) {
 			wg.Add(1)
 			go func(kind string, res *resourceKind) {
 				defer wg.Done()
-				c.collectResource(ctx, metrics, now, myClient, kind, res)
+				c.collectProperties(metrics, res)
+				if !res.propertiesOnly {
+					c.collectResource(ctx, metrics, now, myClient, kind, res)
+				}
 			}(k, r)
 		}
 	}
+	if c.endpoint.events != nil {
+		c.endpoint.events.collect(metrics)
+	}
+	if c.endpoint.cfg.Alarms {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.collectAlarms(ctx, metrics, myClient)
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		c.collectVCenter(ctx, metrics, myClient, skew)
+	}()
+	if c.endpoint.tasks != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.collectTasks(ctx, metrics, myClient, now)
+		}()
+	}
 	wg.Wait()
 }
 
@@ -81,6 +110,10 @@ func (c *vsphereCollector) collectResource(ctx context.Context, metrics chan<- p
 	var refs []types.ManagedObjectReference
 	start := latest.Add(time.Duration(-res.sampling) * time.Second * (time.Duration(c.endpoint.cfg.MetricLookback) - 1))
 	for _, obj := range res.objects {
+		// Objects that aren't running have no performance data to query.
+		if obj.inactive {
+			continue
+		}
 		refs = append(refs, obj.ref)
 	}
 	c.logger.Debug("References to be queried", "refs count", len(refs), "kind", kind)
@@ -173,33 +206,9 @@ func (c *vsphereCollector) collect(ctx context.Context, cli *client, spec types.
 		return nil, err
 	}
 
-	var (
-		parent     string
-		parentType string
-	)
-
 	for _, metric := range result {
 		mo := strings.Split(metric.Entity.String(), ":")[1]
-
-		constLabels := make(prometheus.Labels)
-		constLabels["moid"] = mo
-		constLabels["name"] = c.endpoint.resourceKinds[res.name].objects[mo].name
-
-		// add type/parent labels
-		parent = c.endpoint.resourceKinds[res.name].objects[mo].parentRef.Value
-		parentType = res.parent
-		for parent != "" {
-			if pRes, ok := c.endpoint.resourceKinds[parentType]; ok {
-				if pObj := pRes.objects[parent]; pObj != nil {
-					constLabels[parentType] = pObj.name
-					parent = c.endpoint.resourceKinds[pRes.name].objects[parent].parentRef.Value
-					parentType = pRes.parent
-					continue
-				}
-			}
-			parent = ""
-			parentType = ""
-		}
+		constLabels := c.objectLabels(res, mo)
 
 		for _, v := range metric.Value {
 			counter := counters[v.Name]
@@ -229,6 +238,91 @@ func (c *vsphereCollector) collect(ctx context.Context, cli *client, spec types.
 	return &now, nil
 }
 
+// objectLabels returns the moid and name labels of an object along with the names of its parents.
+func (c *vsphereCollector) objectLabels(res *resourceKind, mo string) prometheus.Labels {
+	var (
+		parent     string
+		parentType string
+	)
+
+	constLabels := make(prometheus.Labels)
+	constLabels["moid"] = mo
+	constLabels["name"] = c.endpoint.resourceKinds[res.name].objects[mo].name
+
+	// add type/parent labels
+	if parentRef := c.endpoint.resourceKinds[res.name].objects[mo].parentRef; parentRef != nil {
+		parent = parentRef.Value
+	}
+	parentType = res.parent
+	for parent != "" {
+		if pRes, ok := c.endpoint.resourceKinds[parentType]; ok {
+			if pObj := pRes.objects[parent]; pObj != nil {
+				constLabels[parentType] = pObj.name
+				parent = ""
+				if pObj.parentRef != nil {
+					parent = pObj.parentRef.Value
+				}
+				parentType = pRes.parent
+				continue
+			}
+		}
+		parent = ""
+		parentType = ""
+	}
+
+	// add labels of related objects that aren't ancestors
+	for kind, moid := range c.endpoint.resourceKinds[res.name].objects[mo].related {
+		if rRes, ok := c.endpoint.resourceKinds[kind]; ok {
+			if rObj := rRes.objects[moid]; rObj != nil {
+				constLabels[kind] = rObj.name
+			}
+		}
+	}
+
+	if c.endpoint.cfg.AttributeLabels {
+		attrs := c.endpoint.resourceKinds[res.name].objects[mo].attributes
+		for k, v := range attributeLabels(attrs, c.endpoint.cfg.CustomAttributes) {
+			constLabels[k] = v
+		}
+	}
+	if c.endpoint.cfg.InventoryPathLabels {
+		constLabels["folder"] = inventoryFolder(c.endpoint.resourceKinds[res.name].objects[mo].inventoryPath)
+	}
+	if c.endpoint.cfg.TagLabels {
+		tags := c.endpoint.resourceKinds[res.name].objects[mo].tags
+		for k, v := range tagLabels(tags, c.endpoint.cfg.TagCategories) {
+			constLabels[k] = v
+		}
+	}
+	return constLabels
+}
+
+// collectProperties sends the property samples gathered for each object during discovery.
+func (c *vsphereCollector) collectProperties(metrics chan<- prometheus.Metric, res *resourceKind) {
+	for mo, obj := range res.objects {
+		if len(obj.samples) == 0 {
+			continue
+		}
+		constLabels := c.objectLabels(res, mo)
+		for _, s := range obj.samples {
+			labels := make(prometheus.Labels, len(constLabels)+len(s.labels))
+			for k, v := range constLabels {
+				labels[k] = v
+			}
+			for k, v := range s.labels {
+				labels[k] = v
+			}
+			desc := prometheus.NewDesc(fmt.Sprintf("vsphere_%s_%s", res.name, s.name), s.help, nil, labels)
+			m, err := prometheus.NewConstMetric(desc, prometheus.GaugeValue, s.value)
+			if err != nil {
+				c.logger.Error("error creating prometheus gauge", "err", err)
+				continue
+			}
+			metrics <- m
+		}
+	}
+}
+
 func newVSphereCollector(ctx context.Context, logger *slog.Logger, e *endpoint) (prometheus.Collector, error) {
 	if logger == nil {
 		logger = promslog.NewNopLogger()
@@ -3,6 +3,7 @@ package vsphere
 import (
 	"flag"
 	"net/url"
+	"strings"
 	"time"
 
 	"github.com/vmware/govmomi/vim25/soap"
@@ -18,6 +19,103 @@ type Config struct {
 	VSphereURL              *url.URL
 	ObjectDiscoveryInterval time.Duration
 	EnableExporterMetrics   bool
+
+	// IncludePaths and ExcludePaths hold the inventory path globs used to
+	// discover objects, keyed by resource kind (e.g. "vm" or "host").
+	IncludePaths map[string][]string
+	ExcludePaths map[string][]string
+
+	// IncludeNames and ExcludeNames hold regular expressions matched against
+	// object names, keyed by resource kind.
+	IncludeNames map[string][]string
+	ExcludeNames map[string][]string
+
+	// IncludeAttributes and ExcludeAttributes hold name=value custom attribute
+	// pairs matched against objects, keyed by resource kind.
+	IncludeAttributes map[string][]string
+	ExcludeAttributes map[string][]string
+
+	// IncludeTags and ExcludeTags hold category=tag pairs matched against the
+	// vSphere tags attached to objects, keyed by resource kind.
+	IncludeTags map[string][]string
+	ExcludeTags map[string][]string
+
+	// DisableDatastoreClusters turns off the collection of datastore clusters.
+	DisableDatastoreClusters bool
+
+	// DisableResourcePools and DisableVApps turn off the discovery and
+	// collection of resource pools and vApps.
+	DisableResourcePools bool
+	DisableVApps         bool
+
+	// DisableNetworks, DisableDVSwitches and DisableDVPortgroups turn off the
+	// discovery and collection of networks, distributed switches and
+	// distributed portgroups.
+	DisableNetworks     bool
+	DisableDVSwitches   bool
+	DisableDVPortgroups bool
+
+	// VMPowerStates restricts discovered virtual machines to the given power
+	// states. VMIncludeGuests and VMExcludeGuests hold regular expressions
+	// matched against the guest ID of a virtual machine.
+	VMPowerStates   []string
+	VMIncludeGuests []string
+	VMExcludeGuests []string
+
+	// VMIncludeTemplates enables discovery of virtual machine templates.
+	VMIncludeTemplates bool
+
+	// CustomAttributes is the allowlist of custom attributes exported for
+	// every object. They are added to the labels of every series when
+	// AttributeLabels is set, and to a custom_attribute_info series otherwise.
+	CustomAttributes []string
+	AttributeLabels  bool
+
+	// TagCategories is the list of vSphere tag categories exported for every
+	// object, either on a tag_info series or on every series when TagLabels
+	// is set.
+	TagCategories []string
+	TagLabels     bool
+
+	// InventoryPath enables an inventory_path_info series per object, and
+	// InventoryPathLabels adds the folder of an object to all its series.
+	InventoryPath       bool
+	InventoryPathLabels bool
+
+	// VMSnapshotInfo enables a series per virtual machine snapshot.
+	VMSnapshotInfo bool
+
+	// Alarms enables the collection of triggered alarms.
+	Alarms bool
+
+	// Sessions and Licenses enable the collection of the active vCenter
+	// sessions and of the license usage. Listing the sessions requires the
+	// Sessions.TerminateSession privilege.
+	Sessions bool
+	Licenses bool
+
+	// Tasks enables the collection of queued, running and completed vCenter
+	// tasks.
+	Tasks bool
+
+	// Events enables counting vCenter events, which are read every
+	// EventsInterval.
+	Events         bool
+	EventsInterval time.Duration
+
+	// EventsForward is the type of sink (loki, cloudevents or file) vCenter
+	// events are forwarded to, and EventsForwardTarget its URL or path. The
+	// last forwarded event is recorded in EventsCheckpointFile.
+	EventsForward        string
+	EventsForwardTarget  string
+	EventsCheckpointFile string
+
+	// HostSensors enables the collection of host hardware health sensors.
+	HostSensors bool
+
+	// VMKubernetesNodeKey is the extraConfig key (e.g. guestinfo.k8s.node)
+	// holding the Kubernetes node name of a virtual machine.
+	VMKubernetesNodeKey string
 }
 
 var defaultConfig = &Config{
@@ -28,6 +126,22 @@ var defaultConfig = &Config{
 	CollectConcurrency:      8,
 	ObjectDiscoveryInterval: 0,
 	EnableExporterMetrics:   false,
+	EventsInterval:          10 * time.Second,
+	IncludePaths: map[string][]string{
+		"datacenter": {"/*"},
+		"cluster":    {"/*/host/**"},
+		"host":       {"/*/host/**"},
+		"vm":         {"/*/vm/**"},
+		"datastore":  {"/*/datastore/**"},
+		// Datastore clusters are named datastore_cluster to match their label.
+		"datastore_cluster": {"/*/datastore/**"},
+		"resource_pool":     {"/*/host/**"},
+		"vapp":              {"/*/host/**"},
+		"network":           {"/*/network/**"},
+		"dvs":               {"/*/network/**"},
+		"dv_portgroup":      {"/*/network/**"},
+	},
+	ExcludePaths: map[string][]string{},
 }
 
 type soapURLFlag struct {
@@ -50,6 +164,70 @@ func (v soapURLFlag) Set(s string) error {
 	return nil
 }
 
+// kindListFlag is a comma separated list flag stored under a resource kind.
+type kindListFlag struct {
+	m    map[string][]string
+	kind string
+}
+
+func (v kindListFlag) String() string {
+	if v.m != nil {
+		return strings.Join(v.m[v.kind], ",")
+	}
+	return ""
+}
+
+func (v kindListFlag) Set(s string) error {
+	v.m[v.kind] = splitList(s)
+	return nil
+}
+
+// listFlag is a comma separated list flag.
+type listFlag struct {
+	l *[]string
+}
+
+func (v listFlag) String() string {
+	if v.l != nil {
+		return strings.Join(*v.l, ",")
+	}
+	return ""
+}
+
+func (v listFlag) Set(s string) error {
+	*v.l = splitList(s)
+	return nil
+}
+
+// labelListFlag is a comma separated list flag of names that are turned into label names with the given prefix.
+type labelListFlag struct {
+	l      *[]string
+	prefix string
+}
+
+func (v labelListFlag) String() string {
+	return listFlag{v.l}.String()
+}
+
+func (v labelListFlag) Set(s string) error {
+	l := splitList(s)
+	if err := checkLabelNames(v.prefix, l); err != nil {
+		return err
+	}
+	*v.l = l
+	return nil
+}
+
+func splitList(s string) []string {
+	var l []string
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			l = append(l, p)
+		}
+	}
+	return l
+}
+
 // RegisterFlags register the flag params for the exporter
 func (c *Config) RegisterFlags(fs *flag.FlagSet) {
 	// Exporter web configs
@@ -77,6 +255,97 @@ func (c *Config) RegisterFlags(fs *flag.FlagSet) {
 			"The number of concurrent requests to make while fetching metrics from vSphere.")
 	}
 
+	// Resource discovery configs
+	{
+		c.IncludePaths = make(map[string][]string, len(defaultConfig.IncludePaths))
+		c.ExcludePaths = make(map[string][]string, len(defaultConfig.IncludePaths))
+		c.IncludeNames = make(map[string][]string, len(defaultConfig.IncludePaths))
+		c.ExcludeNames = make(map[string][]string, len(defaultConfig.IncludePaths))
+		c.IncludeAttributes = make(map[string][]string, len(defaultConfig.IncludePaths))
+		c.ExcludeAttributes = make(map[string][]string, len(defaultConfig.IncludePaths))
+		c.IncludeTags = make(map[string][]string, len(defaultConfig.IncludePaths))
+		c.ExcludeTags = make(map[string][]string, len(defaultConfig.IncludePaths))
+		for kind, paths := range defaultConfig.IncludePaths {
+			c.IncludePaths[kind] = paths
+			fs.Var(kindListFlag{c.IncludePaths, kind}, "vsphere."+kind+".include-paths",
+				"Comma separated list of inventory path globs used to discover "+kind+" objects.")
+			fs.Var(kindListFlag{c.ExcludePaths, kind}, "vsphere."+kind+".exclude-paths",
+				"Comma separated list of inventory path globs of "+kind+" objects to ignore.")
+			fs.Var(kindListFlag{c.IncludeNames, kind}, "vsphere."+kind+".include-names",
+				"Comma separated list of regular expressions. Only "+kind+" objects with a matching name are kept.")
+			fs.Var(kindListFlag{c.ExcludeNames, kind}, "vsphere."+kind+".exclude-names",
+				"Comma separated list of regular expressions. "+kind+" objects with a matching name are ignored.")
+			fs.Var(kindListFlag{c.IncludeAttributes, kind}, "vsphere."+kind+".include-attributes",
+				"Comma separated list of name=value custom attributes. Only "+kind+" objects with a matching attribute are kept.")
+			fs.Var(kindListFlag{c.ExcludeAttributes, kind}, "vsphere."+kind+".exclude-attributes",
+				"Comma separated list of name=value custom attributes. "+kind+" objects with a matching attribute are ignored.")
+			fs.Var(kindListFlag{c.IncludeTags, kind}, "vsphere."+kind+".include-tags",
+				"Comma separated list of category=tag pairs. Only "+kind+" objects with a matching tag are kept.")
+			fs.Var(kindListFlag{c.ExcludeTags, kind}, "vsphere."+kind+".exclude-tags",
+				"Comma separated list of category=tag pairs. "+kind+" objects with a matching tag are ignored.")
+		}
+
+		fs.Var(labelListFlag{&c.CustomAttributes, "attribute_"}, "vsphere.custom-attributes",
+			"Comma separated list of custom attributes exported on a custom_attribute_info series for every object.")
+		fs.BoolVar(&c.AttributeLabels, "vsphere.custom-attributes.all-series", defaultConfig.AttributeLabels,
+			"Add the custom attributes to every series of an object instead of a custom_attribute_info series.")
+		fs.Var(labelListFlag{&c.TagCategories, "tag_"}, "vsphere.tag-categories",
+			"Comma separated list of tag categories exported on a tag_info series for every object.")
+		fs.BoolVar(&c.TagLabels, "vsphere.tag-categories.all-series", defaultConfig.TagLabels,
+			"Add the tags to every series of an object instead of a tag_info series.")
+		fs.BoolVar(&c.InventoryPath, "vsphere.inventory-path", defaultConfig.InventoryPath,
+			"Export the inventory path of every object on an inventory_path_info series.")
+		fs.BoolVar(&c.InventoryPathLabels, "vsphere.inventory-path.all-series", defaultConfig.InventoryPathLabels,
+			"Add the folder of an object to every series of the object.")
+		fs.BoolVar(&c.Alarms, "vsphere.alarms", defaultConfig.Alarms,
+			"Collect the alarms triggered in the inventory.")
+		fs.BoolVar(&c.Sessions, "vsphere.sessions", defaultConfig.Sessions,
+			"Collect the number of active vCenter sessions. Requires the Sessions.TerminateSession privilege.")
+		fs.BoolVar(&c.Licenses, "vsphere.licenses", defaultConfig.Licenses,
+			"Collect the usage, capacity and expiration of vCenter licenses.")
+		fs.BoolVar(&c.Tasks, "vsphere.tasks", defaultConfig.Tasks,
+			"Collect the number of queued, running and completed vCenter tasks and their duration.")
+		fs.BoolVar(&c.Events, "vsphere.events", defaultConfig.Events,
+			"Count vCenter events by event type and entity kind.")
+		fs.DurationVar(&c.EventsInterval, "vsphere.events.interval", defaultConfig.EventsInterval,
+			"Interval at which vCenter events are read.")
+		fs.StringVar(&c.EventsForward, "vsphere.events.forward", defaultConfig.EventsForward,
+			"Forward vCenter events to a sink: loki, cloudevents or file.")
+		fs.StringVar(&c.EventsForwardTarget, "vsphere.events.forward-target", defaultConfig.EventsForwardTarget,
+			"URL or file path events are forwarded to.")
+		fs.StringVar(&c.EventsCheckpointFile, "vsphere.events.checkpoint-file", defaultConfig.EventsCheckpointFile,
+			"File recording the last forwarded event, so that forwarding resumes from it after a restart.")
+
+		fs.BoolVar(&c.DisableDatastoreClusters, "vsphere.datastore_cluster.disable", defaultConfig.DisableDatastoreClusters,
+			"Do not collect datastore clusters.")
+		fs.BoolVar(&c.DisableResourcePools, "vsphere.resource_pool.disable", defaultConfig.DisableResourcePools,
+			"Do not discover and collect resource pools.")
+		fs.BoolVar(&c.DisableVApps, "vsphere.vapp.disable", defaultConfig.DisableVApps,
+			"Do not discover and collect vApps.")
+		fs.BoolVar(&c.DisableNetworks, "vsphere.network.disable", defaultConfig.DisableNetworks,
+			"Do not discover and collect networks.")
+		fs.BoolVar(&c.DisableDVSwitches, "vsphere.dvs.disable", defaultConfig.DisableDVSwitches,
+			"Do not discover and collect distributed switches.")
+		fs.BoolVar(&c.DisableDVPortgroups, "vsphere.dv_portgroup.disable", defaultConfig.DisableDVPortgroups,
+			"Do not discover and collect distributed portgroups.")
+		fs.BoolVar(&c.HostSensors, "vsphere.host.sensors", defaultConfig.HostSensors,
+			"Collect hardware health sensors and hardware status of hosts.")
+		fs.Var(listFlag{&c.VMPowerStates}, "vsphere.vm.power-states",
+			"Comma separated list of power states (poweredOn, poweredOff, suspended) of virtual machines to discover. "+
+				"All virtual machines are discovered if empty.")
+		fs.Var(listFlag{&c.VMIncludeGuests}, "vsphere.vm.include-guests",
+			"Comma separated list of regular expressions. Only virtual machines with a matching guest ID are kept.")
+		fs.Var(listFlag{&c.VMExcludeGuests}, "vsphere.vm.exclude-guests",
+			"Comma separated list of regular expressions. Virtual machines with a matching guest ID are ignored.")
+		fs.BoolVar(&c.VMIncludeTemplates, "vsphere.vm.include-templates", defaultConfig.VMIncludeTemplates,
+			"Discover virtual machine templates.")
+		fs.BoolVar(&c.VMSnapshotInfo, "vsphere.vm.snapshot-info", defaultConfig.VMSnapshotInfo,
+			"Export the name and creation time of every virtual machine snapshot.")
+		fs.StringVar(&c.VMKubernetesNodeKey, "vsphere.vm.kubernetes-node-key", defaultConfig.VMKubernetesNodeKey,
+			"Virtual machine extraConfig key holding the Kubernetes node name. "+
+				"The guest hostname is used if unset or if the key is missing.")
+	}
+
 	// Misc configs
 	{
 		fs.BoolVar(&c.EnableExporterMetrics, "exporter.metrics.enable",
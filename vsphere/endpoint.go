@@ -2,9 +2,11 @@ package vsphere
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
 	"net/url"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -13,6 +15,7 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/vmware/govmomi/object"
 	"github.com/vmware/govmomi/performance"
+	"github.com/vmware/govmomi/property"
 	"github.com/vmware/govmomi/vim25/mo"
 	"github.com/vmware/govmomi/vim25/types"
 )
@@ -21,6 +24,12 @@ var isIPv4 = regexp.MustCompile(`^(?:[0-9]{1,3}\.){3}[0-9]{1,3}$`)
 
 var isIPv6 = regexp.MustCompile(`^(?:[A-Fa-f0-9]{0,4}:){1,7}[A-Fa-f0-9]{1,4}$`)
 
+var vmPowerStates = []string{
+	string(types.VirtualMachinePowerStatePoweredOn),
+	string(types.VirtualMachinePowerStatePoweredOff),
+	string(types.VirtualMachinePowerStateSuspended),
+}
+
 type endpoint struct {
 	cfg              *vSphereConfig
 	url              *url.URL
@@ -32,10 +41,15 @@ type endpoint struct {
 	busy             sync.Mutex
 	metricNameLookup map[int32]string
 	metricNameMux    sync.RWMutex
+	customFieldNames map[int32]string
+	inventoryCache   map[string]inventoryEntity // Names and parents of entities, reset on every discovery
 	log              *slog.Logger
 
 	// discovery meta monitoring
-	dm *discoveryMetrics
+	dm            *discoveryMetrics
+	events        *eventFollower
+	eventsForward *eventFollower
+	tasks         *taskTracker
 }
 
 type resourceKind struct {
@@ -46,7 +60,11 @@ type resourceKind struct {
 	sampling         int32
 	objects          objectMap
 	paths            []string
+	excludePaths     []string
+	filter           *objectFilter
+	fields           []string
 	collectInstances bool
+	propertiesOnly   bool // Set for kinds without performance counters
 	getObjects       func(context.Context, *endpoint, *resourceFilter) (objectMap, error)
 	metrics          performance.MetricList
 	parent           string
@@ -56,16 +74,22 @@ type resourceKind struct {
 type objectMap map[string]*objectRef
 
 type objectRef struct {
-	name      string
-	altID     string
-	ref       types.ManagedObjectReference
-	parentRef *types.ManagedObjectReference //Pointer because it must be nillable
-	guest     string
-	dcname    string
-	lookup    map[string]string
+	name          string
+	altID         string
+	ref           types.ManagedObjectReference
+	parentRef     *types.ManagedObjectReference //Pointer because it must be nillable
+	guest         string
+	dcname        string
+	lookup        map[string]string
+	inactive      bool // Set for objects that aren't running and thus have no performance data
+	samples       []propertySample
+	related       map[string]string   // Resource kind to moid of related objects that aren't ancestors
+	attributes    map[string]string   // Custom attribute values keyed by field name
+	tags          map[string][]string // Names of the attached tags keyed by category name
+	inventoryPath string              // Full inventory path, e.g. /DC1/vm/Prod/api-01
 }
 
-func newEndpoint(cfg *vSphereConfig, url *url.URL, log *slog.Logger, m prometheus.Registerer) *endpoint {
+func newEndpoint(cfg *vSphereConfig, url *url.URL, log *slog.Logger, m prometheus.Registerer) (*endpoint, error) {
 	e := endpoint{
 		cfg:           cfg,
 		url:           url,
@@ -82,7 +106,8 @@ func newEndpoint(cfg *vSphereConfig, url *url.URL, log *slog.Logger, m prometheu
 			realTime:         false,
 			sampling:         int32(cfg.HistoricalInterval.Seconds()),
 			objects:          make(objectMap),
-			paths:            []string{"/*"},
+			paths:            cfg.IncludePaths["datacenter"],
+			excludePaths:     cfg.ExcludePaths["datacenter"],
 			collectInstances: cfg.DatacenterInstances,
 			getObjects:       getDatacenters,
 			parent:           "",
@@ -94,7 +119,8 @@ func newEndpoint(cfg *vSphereConfig, url *url.URL, log *slog.Logger, m prometheu
 			realTime:         false,
 			sampling:         int32(cfg.HistoricalInterval.Seconds()),
 			objects:          make(objectMap),
-			paths:            []string{"/*/host/**"},
+			paths:            cfg.IncludePaths["cluster"],
+			excludePaths:     cfg.ExcludePaths["cluster"],
 			collectInstances: cfg.ClusterInstances,
 			getObjects:       getClusters,
 			parent:           "datacenter",
@@ -106,7 +132,8 @@ func newEndpoint(cfg *vSphereConfig, url *url.URL, log *slog.Logger, m prometheu
 			realTime:         true,
 			sampling:         20,
 			objects:          make(objectMap),
-			paths:            []string{"/*/host/**"},
+			paths:            cfg.IncludePaths["host"],
+			excludePaths:     cfg.ExcludePaths["host"],
 			collectInstances: cfg.HostInstances,
 			getObjects:       getHosts,
 			parent:           "cluster",
@@ -118,7 +145,8 @@ func newEndpoint(cfg *vSphereConfig, url *url.URL, log *slog.Logger, m prometheu
 			realTime:         true,
 			sampling:         20,
 			objects:          make(objectMap),
-			paths:            []string{"/*/vm/**"},
+			paths:            cfg.IncludePaths["vm"],
+			excludePaths:     cfg.ExcludePaths["vm"],
 			collectInstances: cfg.VMInstances,
 			getObjects:       getVMs,
 			parent:           "host",
@@ -130,18 +158,130 @@ func newEndpoint(cfg *vSphereConfig, url *url.URL, log *slog.Logger, m prometheu
 			realTime:         false,
 			sampling:         int32(cfg.HistoricalInterval.Seconds()),
 			objects:          make(objectMap),
-			paths:            []string{"/*/datastore/**"},
+			paths:            cfg.IncludePaths["datastore"],
+			excludePaths:     cfg.ExcludePaths["datastore"],
 			collectInstances: cfg.DatastoreInstances,
 			getObjects:       getDatastores,
+			parent:           "datastore_cluster",
+		},
+		"datastore_cluster": {
+			name:           "datastore_cluster",
+			vcName:         "StoragePod",
+			enabled:        !cfg.DisableDatastoreClusters,
+			propertiesOnly: true,
+			objects:        make(objectMap),
+			paths:          cfg.IncludePaths["datastore_cluster"],
+			excludePaths:   cfg.ExcludePaths["datastore_cluster"],
+			getObjects:     getDatastoreClusters,
+			parent:         "",
+		},
+		"resource_pool": {
+			name:             "resource_pool",
+			vcName:           "ResourcePool",
+			enabled:          !cfg.DisableResourcePools,
+			realTime:         false,
+			sampling:         int32(cfg.HistoricalInterval.Seconds()),
+			objects:          make(objectMap),
+			paths:            cfg.IncludePaths["resource_pool"],
+			excludePaths:     cfg.ExcludePaths["resource_pool"],
+			collectInstances: false,
+			getObjects:       getResourcePools,
 			parent:           "",
 		},
+		"vapp": {
+			name:           "vapp",
+			vcName:         "VirtualApp",
+			enabled:        !cfg.DisableVApps,
+			propertiesOnly: true,
+			objects:        make(objectMap),
+			paths:          cfg.IncludePaths["vapp"],
+			excludePaths:   cfg.ExcludePaths["vapp"],
+			getObjects:     getVApps,
+			parent:         "",
+		},
+		"network": {
+			name:           "network",
+			vcName:         "Network",
+			enabled:        !cfg.DisableNetworks,
+			propertiesOnly: true,
+			objects:        make(objectMap),
+			paths:          cfg.IncludePaths["network"],
+			excludePaths:   cfg.ExcludePaths["network"],
+			getObjects:     getNetworks,
+			parent:         "",
+		},
+		"dvs": {
+			name:           "dvs",
+			vcName:         "DistributedVirtualSwitch",
+			enabled:        !cfg.DisableDVSwitches,
+			propertiesOnly: true,
+			objects:        make(objectMap),
+			paths:          cfg.IncludePaths["dvs"],
+			excludePaths:   cfg.ExcludePaths["dvs"],
+			getObjects:     getDVSwitches,
+			parent:         "",
+		},
+		"dv_portgroup": {
+			name:           "dv_portgroup",
+			vcName:         "DistributedVirtualPortgroup",
+			enabled:        !cfg.DisableDVPortgroups,
+			propertiesOnly: true,
+			objects:        make(objectMap),
+			paths:          cfg.IncludePaths["dv_portgroup"],
+			excludePaths:   cfg.ExcludePaths["dv_portgroup"],
+			getObjects:     getDVPortgroups,
+			parent:         "",
+		},
+	}
+
+	if cfg.HostSensors {
+		e.resourceKinds["host"].fields = append(e.resourceKinds["host"].fields, "runtime.healthSystemRuntime")
+	}
+
+	if err := checkLabelNames("attribute_", cfg.CustomAttributes); err != nil {
+		return nil, fmt.Errorf("invalid custom attributes: %w", err)
+	}
+	if err := checkLabelNames("tag_", cfg.TagCategories); err != nil {
+		return nil, fmt.Errorf("invalid tag categories: %w", err)
+	}
+	for k, res := range e.resourceKinds {
+		f, err := newObjectFilter(k, cfg)
+		if err != nil {
+			return nil, err
+		}
+		res.filter = f
 	}
 
 	if m != nil {
 		e.dm = newDiscoveryMetrics(m)
 	}
+	// Events are counted and forwarded by separate followers, so that the counters keep up while the sink is down
+	// and events are read again from the checkpoint for the sink only.
+	if cfg.Events {
+		f, err := newEventFollower(log.With("component", "events"), &e, "")
+		if err != nil {
+			return nil, err
+		}
+		f.handlers = append(f.handlers, f.count)
+		e.events = f
+	}
+	if cfg.EventsForward != "" {
+		sink, err := newEventSink(cfg.EventsForward, cfg.EventsForwardTarget, cfg.Timeout)
+		if err != nil {
+			return nil, err
+		}
+		f, err := newEventFollower(log.With("component", "events-forward"), &e, cfg.EventsCheckpointFile)
+		if err != nil {
+			return nil, err
+		}
+		f.handlers = append(f.handlers, forwarder(url.Host, sink))
+		e.eventsForward = f
+	}
+	if cfg.Tasks {
+		e.tasks = newTaskTracker()
+	}
 
-	return &e
+	return &e, nil
 }
 
 // Note that canceling the context will cancel the discovery process.
@@ -149,6 +289,12 @@ func (e *endpoint) init(ctx context.Context) error {
 	if e.cfg.ObjectDiscoveryInterval > 0 {
 		e.initialDiscovery(ctx)
 	}
+	if e.events != nil {
+		e.events.start(ctx)
+	}
+	if e.eventsForward != nil {
+		e.eventsForward.start(ctx)
+	}
 	e.initialized = true
 	return nil
 }
@@ -204,21 +350,43 @@ func (e *endpoint) discover(ctx context.Context) error {
 		return err
 	}
 
+	e.customFieldNames, err = client.customFieldNames(ctx)
+	if err != nil {
+		return err
+	}
+
 	e.log.Debug("discover new objects", "host", e.url.Host)
 	dcNameCache := make(map[string]string)
+	e.inventoryCache = make(map[string]inventoryEntity)
 
 	numRes := int64(0)
 
 	// Populate resource objects, and endpoint instance info.
 	newObjects := make(map[string]objectMap)
+	parents := make(map[string]bool)
+	for _, res := range e.resourceKinds {
+		if res.enabled {
+			parents[res.parent] = true
+		}
+	}
+	// The tag catalog is shared by all kinds. An error is handled per kind, as tags are only required by tag filters.
+	var (
+		catalog tagCatalog
+		tagErr  error
+	)
+	if e.needsTags() {
+		catalog, tagErr = e.getTagCatalog(ctx)
+	}
 	for k, res := range e.resourceKinds {
 		e.log.Debug("discovering resources", "name", res.name)
-		// Need to do this for all resource types even if they are not enabled
-		if res.enabled || k != "vm" {
+		// Kinds that are not enabled are still discovered when they label the objects of an enabled kind
+		if res.enabled || parents[k] {
 			rf := resourceFilter{
-				finder:  &finder{client},
-				resType: res.vcName,
-				paths:   res.paths,
+				finder:       &finder{client: client, fields: res.fields},
+				resType:      res.vcName,
+				paths:        res.paths,
+				excludePaths: res.excludePaths,
+				objectFilter: res.filter,
 			}
 
 			ctx1, cancel1 := context.WithTimeout(ctx, e.cfg.Timeout)
@@ -238,8 +406,72 @@ func (e *endpoint) discover(ctx context.Context) error {
 				}
 			}
 
+			if e.cfg.InventoryPath || e.cfg.InventoryPathLabels {
+				if err := e.setInventoryPaths(ctx, client, objects); err != nil {
+					return fmt.Errorf("fetching %s inventory paths: %w", res.name, err)
+				}
+			}
+			if e.cfg.InventoryPath {
+				for _, obj := range objects {
+					obj.samples = append(obj.samples, propertySample{
+						name: "inventory_path_info",
+						help: "Inventory path of the object and the folder holding it.",
+						labels: prometheus.Labels{
+							"inventory_path": obj.inventoryPath,
+							"folder":         inventoryFolder(obj.inventoryPath),
+						},
+						value: 1,
+					})
+				}
+			}
+			tagged := false
+			if len(e.cfg.TagCategories) > 0 || res.filter.selectsTags() {
+				err := tagErr
+				if err == nil {
+					err = e.setObjectTags(ctx, catalog, objects)
+				}
+				if err != nil {
+					// Objects can't be selected by tag without their tags, but tag labels are optional.
+					if res.filter.selectsTags() {
+						return fmt.Errorf("fetching %s tags: %w", res.name, err)
+					}
+					e.log.Warn("error while getting tags, continuing without tag labels", "kind", res.name,
+						"err", err.Error())
+					for _, obj := range objects {
+						obj.tags = nil
+					}
+				} else {
+					tagged = true
+					for k, obj := range objects {
+						if !res.filter.matchTags(obj.tags) {
+							delete(objects, k)
+						}
+					}
+				}
+			}
+			if tagged && len(e.cfg.TagCategories) > 0 && !e.cfg.TagLabels {
+				for _, obj := range objects {
+					obj.samples = append(obj.samples, propertySample{
+						name:   "tag_info",
+						help:   "vSphere tags attached to the object.",
+						labels: tagLabels(obj.tags, e.cfg.TagCategories),
+						value:  1,
+					})
+				}
+			}
+			if len(e.cfg.CustomAttributes) > 0 && !e.cfg.AttributeLabels {
+				for _, obj := range objects {
+					obj.samples = append(obj.samples, propertySample{
+						name:   "custom_attribute_info",
+						help:   "Custom attributes of the object.",
+						labels: attributeLabels(obj.attributes, e.cfg.CustomAttributes),
+						value:  1,
+					})
+				}
+			}
+
 			// No need to collect metric metadata if resource type is not enabled
-			if res.enabled {
+			if res.enabled && !res.propertiesOnly {
 				e.simpleMetadataSelect(ctx, client, res)
 			}
 			newObjects[k] = objects
@@ -367,11 +599,16 @@ func getDatacenters(ctx context.Context, e *endpoint, resourceFilter *resourceFi
 	}
 	m := make(objectMap, len(resources))
 	for _, r := range resources {
+		attrs := customAttributes(e.customFieldNames, r.CustomValue)
+		if !resourceFilter.objectFilter.match(r.Name, attrs) {
+			continue
+		}
 		m[r.ExtensibleManagedObject.Reference().Value] = &objectRef{
-			name:      r.Name,
-			ref:       r.ExtensibleManagedObject.Reference(),
-			parentRef: r.Parent,
-			dcname:    r.Name,
+			name:       r.Name,
+			ref:        r.ExtensibleManagedObject.Reference(),
+			attributes: attrs,
+			parentRef:  r.Parent,
+			dcname:     r.Name,
 		}
 	}
 	return m, nil
@@ -388,6 +625,10 @@ func getClusters(ctx context.Context, e *endpoint, resourceFilter *resourceFilte
 	cache := make(map[string]*types.ManagedObjectReference)
 	m := make(objectMap, len(resources))
 	for _, r := range resources {
+		attrs := customAttributes(e.customFieldNames, r.CustomValue)
+		if !resourceFilter.objectFilter.match(r.Name, attrs) {
+			continue
+		}
 		// Wrap in a function to make defer work correctly.
 		err := func() error {
 			// We're not interested in the immediate parent (a folder), but the data center.
@@ -414,9 +655,11 @@ func getClusters(ctx context.Context, e *endpoint, resourceFilter *resourceFilte
 				}
 			}
 			m[r.ExtensibleManagedObject.Reference().Value] = &objectRef{
-				name:      r.Name,
-				ref:       r.ExtensibleManagedObject.Reference(),
-				parentRef: p,
+				name:       r.Name,
+				ref:        r.ExtensibleManagedObject.Reference(),
+				attributes: attrs,
+				parentRef:  p,
+				samples:    clusterSamples(&r),
 			}
 			if e.dm != nil {
 				e.dm.clusters.Inc()
@@ -430,7 +673,7 @@ func getClusters(ctx context.Context, e *endpoint, resourceFilter *resourceFilte
 	return m, nil
 }
 
-func getHosts(ctx context.Context, _ *endpoint, resourceFilter *resourceFilter) (objectMap, error) {
+func getHosts(ctx context.Context, e *endpoint, resourceFilter *resourceFilter) (objectMap, error) {
 	var resources []mo.HostSystem
 	err := resourceFilter.findAll(ctx, &resources)
 	if err != nil {
@@ -438,10 +681,19 @@ func getHosts(ctx context.Context, _ *endpoint, resourceFilter *resourceFilter)
 	}
 	m := make(objectMap)
 	for _, r := range resources {
+		attrs := customAttributes(e.customFieldNames, r.CustomValue)
+		if !resourceFilter.objectFilter.match(r.Name, attrs) {
+			continue
+		}
+		samples := append(hostSamples(&r), hostHealthSamples(&r)...)
+		samples = append(samples, hostPNICSamples(&r)...)
 		m[r.ExtensibleManagedObject.Reference().Value] = &objectRef{
-			name:      r.Name,
-			ref:       r.ExtensibleManagedObject.Reference(),
-			parentRef: r.Parent,
+			name:       r.Name,
+			ref:        r.ExtensibleManagedObject.Reference(),
+			attributes: attrs,
+			parentRef:  r.Parent,
+			inactive:   r.Runtime.ConnectionState != types.HostSystemConnectionStateConnected,
+			samples:    samples,
 		}
 	}
 	return m, nil
@@ -455,14 +707,38 @@ func getVMs(ctx context.Context, e *endpoint, resourceFilter *resourceFilter) (o
 	if err != nil {
 		return nil, err
 	}
+	var nodeNames map[string]string
+	if e.cfg.VMKubernetesNodeKey != "" {
+		nodeNames, err = e.getExtraConfigValues(ctx1, resources, e.cfg.VMKubernetesNodeKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+	datastoreNames, err := e.getDatastoreNames(ctx1, resources)
+	if err != nil {
+		return nil, err
+	}
+	networkNames, err := e.getNetworkNames(ctx1, resources)
+	if err != nil {
+		return nil, err
+	}
 	m := make(objectMap)
 	for _, r := range resources {
-		if r.Runtime.PowerState != "poweredOn" {
+		guestID := ""
+		if r.Config != nil {
+			if r.Config.Template && !e.cfg.VMIncludeTemplates {
+				continue
+			}
+			guestID = r.Config.GuestId
+		}
+		attrs := customAttributes(e.customFieldNames, r.CustomValue)
+		if !resourceFilter.objectFilter.matchVM(r.Name, attrs, string(r.Runtime.PowerState), guestID) {
 			continue
 		}
 		guest := "unknown"
 		uuid := ""
 		lookup := make(map[string]string)
+		primaryIPs := make(map[string]string)
 
 		// Extract host name
 		if r.Guest != nil && r.Guest.HostName != "" {
@@ -470,7 +746,11 @@ func getVMs(ctx context.Context, e *endpoint, resourceFilter *resourceFilter) (o
 		}
 
 		// Collect network information
-		for _, net := range r.Guest.Net {
+		var nics []types.GuestNicInfo
+		if r.Guest != nil {
+			nics = r.Guest.Net
+		}
+		for _, net := range nics {
 			if net.DeviceConfigId == -1 {
 				continue
 			}
@@ -500,6 +780,10 @@ func getVMs(ctx context.Context, e *endpoint, resourceFilter *resourceFilter) (o
 			}
 			for ipType, ipList := range ips {
 				lookup["nic/"+strconv.Itoa(int(net.DeviceConfigId))+"/"+ipType] = strings.Join(ipList, ",")
+				// The first address of the first NIC is considered the primary address.
+				if _, ok := primaryIPs[ipType]; !ok {
+					primaryIPs[ipType] = ipList[0]
+				}
 			}
 		}
 
@@ -509,18 +793,422 @@ func getVMs(ctx context.Context, e *endpoint, resourceFilter *resourceFilter) (o
 			uuid = r.Config.Uuid
 		}
 
+		powerState := string(r.Runtime.PowerState)
+		obj := &objectRef{
+			name:       r.Name,
+			ref:        r.ExtensibleManagedObject.Reference(),
+			attributes: attrs,
+			parentRef:  r.Runtime.Host,
+			guest:      guest,
+			altID:      uuid,
+			lookup:     lookup,
+			inactive:   powerState != string(types.VirtualMachinePowerStatePoweredOn),
+			samples: stateSetSamples("power_state", "Power state of the virtual machine.", "state",
+				vmPowerStates, powerState),
+		}
+		obj.related = make(map[string]string)
+		if rp := r.ResourcePool; rp != nil {
+			// A virtual machine in a vApp has the vApp as its resource pool.
+			if rp.Type == "VirtualApp" {
+				obj.related["vapp"] = rp.Value
+			} else {
+				obj.related["resource_pool"] = rp.Value
+			}
+		}
+		if r.ParentVApp != nil {
+			obj.related["vapp"] = r.ParentVApp.Value
+		}
+		obj.samples = append(obj.samples, vmInfoSample(&r, primaryIPs))
+		obj.samples = append(obj.samples, vmGuestSamples(&r)...)
+		obj.samples = append(obj.samples, vmGuestDiskSamples(&r)...)
+		obj.samples = append(obj.samples, vmResourceSamples(&r)...)
+		obj.samples = append(obj.samples, vmStorageSamples(&r, datastoreNames)...)
+		obj.samples = append(obj.samples, vmNetworkSamples(&r, networkNames)...)
+		obj.samples = append(obj.samples, vmSnapshotSamples(&r, e.cfg.VMSnapshotInfo)...)
+		if uuid != "" {
+			obj.samples = append(obj.samples, vmKubernetesNodeSample(&r, nodeNames[r.Self.Value]))
+		}
+		m[r.ExtensibleManagedObject.Reference().Value] = obj
+	}
+	return m, nil
+}
+
+func getDatastoreClusters(ctx context.Context, e *endpoint, resourceFilter *resourceFilter) (objectMap, error) {
+	var resources []mo.StoragePod
+	ctx1, cancel1 := context.WithTimeout(ctx, e.cfg.Timeout)
+	defer cancel1()
+	err := resourceFilter.findAll(ctx1, &resources)
+	if err != nil {
+		return nil, err
+	}
+	m := make(objectMap)
+	for _, r := range resources {
+		attrs := customAttributes(e.customFieldNames, r.CustomValue)
+		if !resourceFilter.objectFilter.match(r.Name, attrs) {
+			continue
+		}
+		m[r.ExtensibleManagedObject.Reference().Value] = &objectRef{
+			name:       r.Name,
+			ref:        r.ExtensibleManagedObject.Reference(),
+			attributes: attrs,
+			parentRef:  r.Parent,
+			samples:    datastoreClusterSamples(&r),
+		}
+	}
+	return m, nil
+}
+
+func getResourcePools(ctx context.Context, e *endpoint, resourceFilter *resourceFilter) (objectMap, error) {
+	return getPools(ctx, e, resourceFilter, "ResourcePool")
+}
+
+func getVApps(ctx context.Context, e *endpoint, resourceFilter *resourceFilter) (objectMap, error) {
+	return getPools(ctx, e, resourceFilter, "VirtualApp")
+}
+
+// getPools returns the resource pools of the given type. Resource pools and vApps are loaded the same way since a
+// vApp is a special kind of resource pool.
+func getPools(ctx context.Context, e *endpoint, resourceFilter *resourceFilter, poolType string) (objectMap, error) {
+	var resources []mo.ResourcePool
+	ctx1, cancel1 := context.WithTimeout(ctx, e.cfg.Timeout)
+	defer cancel1()
+	err := resourceFilter.findAll(ctx1, &resources)
+	if err != nil {
+		return nil, err
+	}
+	client, err := e.clientFactory.GetClient(ctx1)
+	if err != nil {
+		return nil, err
+	}
+	// Parent pools are looked up in the inventory since the include and exclude paths may leave them out.
+	refs := make([]types.ManagedObjectReference, 0, len(resources))
+	for _, r := range resources {
+		refs = append(refs, r.Self)
+	}
+	if err := e.loadInventory(ctx1, client, refs); err != nil {
+		return nil, err
+	}
+	m := make(objectMap)
+	for _, r := range resources {
+		// Looking up resource pools also returns vApps.
+		if r.Self.Type != poolType {
+			continue
+		}
+		attrs := customAttributes(e.customFieldNames, r.CustomValue)
+		if !resourceFilter.objectFilter.match(r.Name, attrs) {
+			continue
+		}
+		m[r.ExtensibleManagedObject.Reference().Value] = &objectRef{
+			name:       r.Name,
+			ref:        r.ExtensibleManagedObject.Reference(),
+			attributes: attrs,
+			parentRef:  r.Parent,
+			related:    map[string]string{"cluster": r.Owner.Value},
+			samples:    poolSamples(&r, poolPath(e.inventoryCache, &r)),
+		}
+	}
+	return m, nil
+}
+
+// poolPath returns the path of a resource pool below the root resource pool of its cluster, e.g. Resources/Prod/Web.
+// The names of the parent pools and vApps are taken from the inventory cache.
+func poolPath(cache map[string]inventoryEntity, r *mo.ResourcePool) string {
+	path := []string{r.Name}
+	for p := r.Parent; p != nil && (p.Type == "ResourcePool" || p.Type == "VirtualApp"); {
+		parent, ok := cache[p.Value]
+		if !ok {
+			break
+		}
+		path = append([]string{parent.name}, path...)
+		p = parent.parent
+	}
+	return strings.Join(path, "/")
+}
+
+func getNetworks(ctx context.Context, e *endpoint, resourceFilter *resourceFilter) (objectMap, error) {
+	var resources []mo.Network
+	ctx1, cancel1 := context.WithTimeout(ctx, e.cfg.Timeout)
+	defer cancel1()
+	err := resourceFilter.findAll(ctx1, &resources)
+	if err != nil {
+		return nil, err
+	}
+	// Looking up networks also returns distributed portgroups, which are discovered as their own kind.
+	networks := resources[:0]
+	for _, r := range resources {
+		if r.Self.Type != "DistributedVirtualPortgroup" {
+			networks = append(networks, r)
+		}
+	}
+	vlans, err := e.getStandardPortgroupVlans(ctx1, networks)
+	if err != nil {
+		return nil, err
+	}
+	m := make(objectMap)
+	for _, r := range networks {
+		attrs := customAttributes(e.customFieldNames, r.CustomValue)
+		if !resourceFilter.objectFilter.match(r.Name, attrs) {
+			continue
+		}
 		m[r.ExtensibleManagedObject.Reference().Value] = &objectRef{
-			name:      r.Name,
-			ref:       r.ExtensibleManagedObject.Reference(),
-			parentRef: r.Runtime.Host,
-			guest:     guest,
-			altID:     uuid,
-			lookup:    lookup,
+			name:       r.Name,
+			ref:        r.ExtensibleManagedObject.Reference(),
+			attributes: attrs,
+			parentRef:  r.Parent,
+			samples:    networkSamples(&r, vlans[r.Self.Value]),
 		}
 	}
 	return m, nil
 }
 
+func getDVSwitches(ctx context.Context, e *endpoint, resourceFilter *resourceFilter) (objectMap, error) {
+	var resources []mo.DistributedVirtualSwitch
+	ctx1, cancel1 := context.WithTimeout(ctx, e.cfg.Timeout)
+	defer cancel1()
+	err := resourceFilter.findAll(ctx1, &resources)
+	if err != nil {
+		return nil, err
+	}
+	m := make(objectMap)
+	for _, r := range resources {
+		attrs := customAttributes(e.customFieldNames, r.CustomValue)
+		if !resourceFilter.objectFilter.match(r.Name, attrs) {
+			continue
+		}
+		m[r.ExtensibleManagedObject.Reference().Value] = &objectRef{
+			name:       r.Name,
+			ref:        r.ExtensibleManagedObject.Reference(),
+			attributes: attrs,
+			parentRef:  r.Parent,
+			samples:    dvsSamples(&r),
+		}
+	}
+	return m, nil
+}
+
+func getDVPortgroups(ctx context.Context, e *endpoint, resourceFilter *resourceFilter) (objectMap, error) {
+	var resources []mo.DistributedVirtualPortgroup
+	ctx1, cancel1 := context.WithTimeout(ctx, e.cfg.Timeout)
+	defer cancel1()
+	err := resourceFilter.findAll(ctx1, &resources)
+	if err != nil {
+		return nil, err
+	}
+	usedPorts := e.getUsedDVPorts(ctx1, resources)
+	m := make(objectMap)
+	for _, r := range resources {
+		attrs := customAttributes(e.customFieldNames, r.CustomValue)
+		if !resourceFilter.objectFilter.match(r.Name, attrs) {
+			continue
+		}
+		obj := &objectRef{
+			name:       r.Name,
+			ref:        r.ExtensibleManagedObject.Reference(),
+			attributes: attrs,
+			parentRef:  r.Parent,
+			samples:    dvPortgroupSamples(&r, usedPorts),
+		}
+		if dvs := r.Config.DistributedVirtualSwitch; dvs != nil {
+			obj.related = map[string]string{"dvs": dvs.Value}
+		}
+		m[r.ExtensibleManagedObject.Reference().Value] = obj
+	}
+	return m, nil
+}
+
+// getUsedDVPorts returns the number of connected ports of the given distributed portgroups, keyed by portgroup key.
+// Portgroups whose ports can't be fetched are left out, so that they are still discovered without port usage.
+func (e *endpoint) getUsedDVPorts(ctx context.Context, portgroups []mo.DistributedVirtualPortgroup) map[string]int {
+	used := make(map[string]int)
+	keys := make(map[types.ManagedObjectReference][]string)
+	for _, pg := range portgroups {
+		if dvs := pg.Config.DistributedVirtualSwitch; dvs != nil {
+			keys[*dvs] = append(keys[*dvs], pg.Key)
+		}
+	}
+	if len(keys) == 0 {
+		return used
+	}
+	client, err := e.clientFactory.GetClient(ctx)
+	if err != nil {
+		e.log.Warn("error while getting distributed ports, continuing without port usage", "err", err.Error())
+		return used
+	}
+	for ref, pgKeys := range keys {
+		dvs := object.NewDistributedVirtualSwitch(client.Client.Client, ref)
+		ports, err := dvs.FetchDVPorts(ctx, &types.DistributedVirtualSwitchPortCriteria{
+			Connected:    types.NewBool(true),
+			Inside:       types.NewBool(true),
+			PortgroupKey: pgKeys,
+		})
+		if err != nil {
+			e.log.Warn("error while getting distributed ports, continuing without port usage", "dvs", ref.Value, "err", err.Error())
+			continue
+		}
+		for _, k := range pgKeys {
+			used[k] = 0
+		}
+		for _, p := range ports {
+			used[p.PortgroupKey]++
+		}
+	}
+	return used
+}
+
+// getStandardPortgroupVlans returns the VLAN IDs of the standard portgroups backing the given networks on their
+// hosts, keyed by network moid.
+func (e *endpoint) getStandardPortgroupVlans(ctx context.Context, networks []mo.Network) (map[string][]int32, error) {
+	seen := make(map[string]bool)
+	var refs []types.ManagedObjectReference
+	for _, n := range networks {
+		if n.Self.Type != "Network" {
+			continue
+		}
+		for _, h := range n.Host {
+			if !seen[h.Value] {
+				seen[h.Value] = true
+				refs = append(refs, h)
+			}
+		}
+	}
+	if len(refs) == 0 {
+		return make(map[string][]int32), nil
+	}
+	client, err := e.clientFactory.GetClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var hosts []mo.HostSystem
+	pc := property.DefaultCollector(client.Client.Client)
+	if err := pc.Retrieve(ctx, refs, []string{"config.network.portgroup"}, &hosts); err != nil {
+		return nil, err
+	}
+	return portgroupVlans(networks, hosts), nil
+}
+
+// portgroupVlans matches networks with the standard portgroups of the same name on their hosts and returns the
+// sorted VLAN IDs of those portgroups, keyed by network moid. A portgroup can have a different VLAN ID on each host,
+// and portgroups of the same name on hosts of other datacenters back other networks.
+func portgroupVlans(networks []mo.Network, hosts []mo.HostSystem) map[string][]int32 {
+	// VLAN ID keyed by host moid and portgroup name
+	hostVlans := make(map[[2]string]int32)
+	for _, h := range hosts {
+		if h.Config == nil || h.Config.Network == nil {
+			continue
+		}
+		for _, pg := range h.Config.Network.Portgroup {
+			hostVlans[[2]string{h.Self.Value, pg.Spec.Name}] = pg.Spec.VlanId
+		}
+	}
+	vlans := make(map[string][]int32)
+	for _, n := range networks {
+		if n.Self.Type != "Network" {
+			continue
+		}
+		var ids []int32
+		seen := make(map[int32]bool)
+		for _, h := range n.Host {
+			if id, ok := hostVlans[[2]string{h.Value, n.Name}]; ok && !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+		vlans[n.Self.Value] = ids
+	}
+	return vlans
+}
+
+// getExtraConfigValues returns the value of the given extraConfig key for each of the virtual machines that has it.
+func (e *endpoint) getExtraConfigValues(ctx context.Context, vms []mo.VirtualMachine, key string) (map[string]string, error) {
+	values := make(map[string]string)
+	if len(vms) == 0 {
+		return values, nil
+	}
+	client, err := e.clientFactory.GetClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	refs := make([]types.ManagedObjectReference, 0, len(vms))
+	for _, vm := range vms {
+		refs = append(refs, vm.Self)
+	}
+	var resources []mo.VirtualMachine
+	pc := property.DefaultCollector(client.Client.Client)
+	if err := pc.Retrieve(ctx, refs, []string{"config.extraConfig"}, &resources); err != nil {
+		return nil, err
+	}
+	for _, r := range resources {
+		if r.Config == nil {
+			continue
+		}
+		for _, opt := range r.Config.ExtraConfig {
+			if ov := opt.GetOptionValue(); ov.Key == key {
+				if v, ok := ov.Value.(string); ok {
+					values[r.Self.Value] = v
+				}
+			}
+		}
+	}
+	return values, nil
+}
+
+// getDatastoreNames returns the names of the datastores used by the given virtual machines, keyed by moid.
+func (e *endpoint) getDatastoreNames(ctx context.Context, vms []mo.VirtualMachine) (map[string]string, error) {
+	var refs []types.ManagedObjectReference
+	for _, vm := range vms {
+		if vm.Storage == nil {
+			continue
+		}
+		for _, u := range vm.Storage.PerDatastoreUsage {
+			refs = append(refs, u.Datastore)
+		}
+	}
+	return e.getNames(ctx, refs)
+}
+
+// getNetworkNames returns the names of the networks the given virtual machines are connected to, keyed by moid.
+func (e *endpoint) getNetworkNames(ctx context.Context, vms []mo.VirtualMachine) (map[string]string, error) {
+	var refs []types.ManagedObjectReference
+	for _, vm := range vms {
+		refs = append(refs, vm.Network...)
+	}
+	return e.getNames(ctx, refs)
+}
+
+// getNames returns the names of the given managed entities, keyed by moid.
+func (e *endpoint) getNames(ctx context.Context, refs []types.ManagedObjectReference) (map[string]string, error) {
+	names := make(map[string]string)
+	seen := make(map[types.ManagedObjectReference]bool)
+	var unique []types.ManagedObjectReference
+	for _, ref := range refs {
+		if !seen[ref] {
+			seen[ref] = true
+			unique = append(unique, ref)
+		}
+	}
+	if len(unique) == 0 {
+		return names, nil
+	}
+	client, err := e.clientFactory.GetClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var content []types.ObjectContent
+	pc := property.DefaultCollector(client.Client.Client)
+	if err := pc.Retrieve(ctx, unique, []string{"name"}, &content); err != nil {
+		return nil, err
+	}
+	for _, c := range content {
+		for _, p := range c.PropSet {
+			if name, ok := p.Val.(string); ok && p.Name == "name" {
+				names[c.Obj.Value] = name
+			}
+		}
+	}
+	return names, nil
+}
+
 func getDatastores(ctx context.Context, e *endpoint, resourceFilter *resourceFilter) (objectMap, error) {
 	var resources []mo.Datastore
 	ctx1, cancel1 := context.WithTimeout(ctx, e.cfg.Timeout)
@@ -531,6 +1219,10 @@ func getDatastores(ctx context.Context, e *endpoint, resourceFilter *resourceFil
 	}
 	m := make(objectMap)
 	for _, r := range resources {
+		attrs := customAttributes(e.customFieldNames, r.CustomValue)
+		if !resourceFilter.objectFilter.match(r.Name, attrs) {
+			continue
+		}
 		lunID := ""
 		if r.Info != nil {
 			info := r.Info.GetDatastoreInfo()
@@ -539,10 +1231,13 @@ func getDatastores(ctx context.Context, e *endpoint, resourceFilter *resourceFil
 			}
 		}
 		m[r.ExtensibleManagedObject.Reference().Value] = &objectRef{
-			name:      r.Name,
-			ref:       r.ExtensibleManagedObject.Reference(),
-			parentRef: r.Parent,
-			altID:     lunID,
+			name:       r.Name,
+			ref:        r.ExtensibleManagedObject.Reference(),
+			attributes: attrs,
+			parentRef:  r.Parent,
+			altID:      lunID,
+			inactive:   !r.Summary.Accessible,
+			samples:    datastoreSamples(&r, lunID),
 		}
 	}
 	return m, nil
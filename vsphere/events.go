@@ -0,0 +1,272 @@
+package vsphere
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/vmware/govmomi/event"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// eventPageSize is the number of events read from the history collector at once.
+const eventPageSize = 100
+
+var eventsDesc = prometheus.NewDesc("vsphere_events_total",
+	"Number of vCenter events by event type and kind of the entity they relate to.",
+	[]string{"event_type", "entity_kind"}, nil)
+
+// eventHandler is called with every batch of new events, oldest first.
+type eventHandler func(ctx context.Context, events []types.BaseEvent) error
+
+// eventFollower follows the vCenter event stream with an EventHistoryCollector. The key and creation time of the
+// last event handled are kept as a checkpoint, so that a collector lost along with its session is recreated from
+// where the previous one stopped without handing out events twice. The checkpoint is also written to
+// checkpointFile, when set, to carry it over restarts.
+type eventFollower struct {
+	log            *slog.Logger
+	endpoint       *endpoint
+	interval       time.Duration
+	collector      *event.HistoryCollector
+	checkpoint     eventCheckpoint
+	checkpointFile string
+	handlers       []eventHandler
+
+	mux    sync.Mutex
+	counts map[eventCount]float64
+}
+
+// eventCheckpoint is the key and creation time of the last event handled.
+type eventCheckpoint struct {
+	Key  int32     `json:"key"`
+	Time time.Time `json:"time"`
+}
+
+type eventCount struct {
+	eventType  string
+	entityKind string
+}
+
+// newEventFollower creates a follower starting at the checkpoint stored in checkpointFile, or at the current time if
+// there is none. Handlers are added by the caller.
+func newEventFollower(log *slog.Logger, e *endpoint, checkpointFile string) (*eventFollower, error) {
+	f := &eventFollower{
+		log:            log,
+		endpoint:       e,
+		interval:       e.cfg.EventsInterval,
+		checkpoint:     eventCheckpoint{Time: time.Now()},
+		checkpointFile: checkpointFile,
+		counts:         make(map[eventCount]float64),
+	}
+	if checkpointFile == "" {
+		return f, nil
+	}
+	b, err := os.ReadFile(checkpointFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return f, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &f.checkpoint); err != nil {
+		return nil, fmt.Errorf("invalid event checkpoint %s: %w", checkpointFile, err)
+	}
+	return f, nil
+}
+
+// saveCheckpoint writes the checkpoint to the checkpoint file. The file is replaced atomically so that a crash
+// can't leave a truncated checkpoint behind.
+func (f *eventFollower) saveCheckpoint() error {
+	if f.checkpointFile == "" {
+		return nil
+	}
+	b, err := json.Marshal(f.checkpoint)
+	if err != nil {
+		return err
+	}
+	tmp := f.checkpointFile + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, f.checkpointFile)
+}
+
+// start polls for new events every interval until the context is canceled.
+func (f *eventFollower) start(ctx context.Context) {
+	ticker := time.NewTicker(f.interval)
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				if err := f.poll(ctx); err != nil && err != context.Canceled {
+					f.log.Error("error reading events", "err", err)
+				}
+			case <-ctx.Done():
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+}
+
+// poll reads all events posted since the last call and passes them to the handlers.
+func (f *eventFollower) poll(ctx context.Context) error {
+	if f.collector == nil {
+		client, err := f.endpoint.clientFactory.GetClient(ctx)
+		if err != nil {
+			return err
+		}
+		ctx1, cancel1 := context.WithTimeout(ctx, f.endpoint.cfg.Timeout)
+		defer cancel1()
+		since := f.checkpoint.Time
+		spec := types.EventFilterSpec{Time: &types.EventFilterSpecByTime{BeginTime: &since}}
+		f.collector, err = event.NewManager(client.Client.Client).CreateCollectorForEvents(ctx1, spec)
+		if err != nil {
+			return err
+		}
+	}
+
+	for {
+		ctx1, cancel1 := context.WithTimeout(ctx, f.endpoint.cfg.Timeout)
+		events, err := f.collector.ReadNextEvents(ctx1, eventPageSize)
+		cancel1()
+		if err != nil {
+			// The collector goes away with the session. Start over from the checkpoint on the next poll.
+			f.destroyCollector(ctx)
+			return err
+		}
+
+		next := f.checkpoint
+		fresh := events[:0]
+		for _, ev := range events {
+			e := ev.GetEvent()
+			if e.Key <= next.Key {
+				continue
+			}
+			fresh = append(fresh, ev)
+			next = eventCheckpoint{Key: e.Key, Time: e.CreatedTime}
+		}
+		if len(fresh) > 0 {
+			for _, h := range f.handlers {
+				if err := h(ctx, fresh); err != nil {
+					// Read the events again from the checkpoint on the next poll.
+					f.destroyCollector(ctx)
+					return err
+				}
+			}
+			f.checkpoint = next
+			if err := f.saveCheckpoint(); err != nil {
+				return err
+			}
+		}
+		if len(events) < eventPageSize {
+			return nil
+		}
+	}
+}
+
+// destroyCollector removes the history collector from the server, which would otherwise keep it until the session
+// ends, so that the next poll creates a new one.
+func (f *eventFollower) destroyCollector(ctx context.Context) {
+	ctx1, cancel1 := context.WithTimeout(ctx, f.endpoint.cfg.Timeout)
+	defer cancel1()
+	if err := f.collector.Destroy(ctx1); err != nil {
+		f.log.Debug("error destroying the event history collector", "err", err)
+	}
+	f.collector = nil
+}
+
+// count is the event handler incrementing the event counters.
+func (f *eventFollower) count(_ context.Context, events []types.BaseEvent) error {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	for _, ev := range events {
+		kind, _ := eventEntity(ev)
+		f.counts[eventCount{eventType: eventType(ev), entityKind: kind}]++
+	}
+	return nil
+}
+
+// collect sends the event counters.
+func (f *eventFollower) collect(metrics chan<- prometheus.Metric) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	for c, v := range f.counts {
+		metrics <- prometheus.MustNewConstMetric(eventsDesc, prometheus.CounterValue, v, c.eventType, c.entityKind)
+	}
+}
+
+// eventType returns the name of the event type, e.g. VmMigratedEvent, or the event type ID of extended events.
+func eventType(ev types.BaseEvent) string {
+	switch e := ev.(type) {
+	case *types.EventEx:
+		if e.EventTypeId != "" {
+			return e.EventTypeId
+		}
+	case *types.ExtendedEvent:
+		if e.EventTypeId != "" {
+			return e.EventTypeId
+		}
+	}
+	return reflect.TypeOf(ev).Elem().Name()
+}
+
+// eventEntity returns the resource kind and name of the most specific entity an event relates to.
+func eventEntity(ev types.BaseEvent) (string, string) {
+	e := ev.GetEvent()
+	switch {
+	case e.Vm != nil:
+		return "vm", e.Vm.Name
+	case e.Host != nil:
+		return "host", e.Host.Name
+	case e.Ds != nil:
+		return "datastore", e.Ds.Name
+	case e.Net != nil:
+		return "network", e.Net.Name
+	case e.Dvs != nil:
+		return "dvs", e.Dvs.Name
+	case e.ComputeResource != nil:
+		return "cluster", e.ComputeResource.Name
+	case e.Datacenter != nil:
+		return "datacenter", e.Datacenter.Name
+	}
+	return "none", ""
+}
+
+// entityKind returns the kind of the given entity, named like the entity kinds of events.
+func entityKind(ref *types.ManagedObjectReference) string {
+	if ref == nil {
+		return "none"
+	}
+	switch ref.Type {
+	case "VirtualMachine":
+		return "vm"
+	case "HostSystem":
+		return "host"
+	case "Datastore":
+		return "datastore"
+	case "Network", "OpaqueNetwork", "DistributedVirtualPortgroup":
+		return "network"
+	case "VmwareDistributedVirtualSwitch", "DistributedVirtualSwitch":
+		return "dvs"
+	case "ClusterComputeResource", "ComputeResource":
+		return "cluster"
+	case "Datacenter":
+		return "datacenter"
+	case "StoragePod":
+		return "datastore_cluster"
+	case "ResourcePool":
+		return "resource_pool"
+	case "VirtualApp":
+		return "vapp"
+	}
+	return strings.ToLower(ref.Type)
+}
@@ -0,0 +1,168 @@
+package vsphere
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/event"
+	"github.com/vmware/govmomi/history"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+func TestEventFollower(t *testing.T) {
+	m, s, err := createSim(0)
+	defer m.Remove()
+	defer s.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	cfg := *defaultVSphere
+	cfg.Events = true
+	e, err := newEndpoint(&cfg, s.URL, slog.Default(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Events posted while the simulator was built are older than the follower and aren't counted.
+	if err := e.events.poll(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := govmomi.NewClient(ctx, s.URL, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vm := simulator.Map.Any("VirtualMachine").Reference()
+	host := simulator.Map.Any("HostSystem").Reference()
+	em := event.NewManager(c.Client)
+	migrated := &types.VmMigratedEvent{
+		VmEvent:         types.VmEvent{Event: types.Event{Vm: &types.VmEventArgument{Vm: vm}}},
+		SourceHost:      types.HostEventArgument{Host: host},
+		SourceDatastore: &types.DatastoreEventArgument{},
+	}
+	posts := []types.BaseEvent{
+		migrated,
+		migrated,
+		&types.HostConnectionLostEvent{HostEvent: types.HostEvent{Event: types.Event{
+			Host: &types.HostEventArgument{Host: host}}}},
+		&types.BadUsernameSessionEvent{},
+	}
+	for _, ev := range posts {
+		if err := em.PostEvent(ctx, ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := e.events.poll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// A poll without new events must not count anything twice.
+	if err := e.events.poll(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(map[string]float64)
+	ch := make(chan prometheus.Metric, 10)
+	e.events.collect(ch)
+	close(ch)
+	for metric := range ch {
+		var pb dto.Metric
+		if err := metric.Write(&pb); err != nil {
+			t.Fatal(err)
+		}
+		labels := make(map[string]string)
+		for _, l := range pb.Label {
+			labels[l.GetName()] = l.GetValue()
+		}
+		got[labels["event_type"]+"/"+labels["entity_kind"]] = pb.Counter.GetValue()
+	}
+	want := map[string]float64{
+		"VmMigratedEvent/vm":           2,
+		"HostConnectionLostEvent/host": 1,
+		"BadUsernameSessionEvent/none": 1,
+		// The exporter and the test client both logged in after the follower was created.
+		"UserLoginSessionEvent/none": 2,
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("events_total{%s} = %v, want %v", k, got[k], v)
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("got counters %v, want %v", got, want)
+	}
+}
+
+func TestEventFollowerHandlerError(t *testing.T) {
+	m, s, err := createSim(0)
+	defer m.Remove()
+	defer s.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	cfg := *defaultVSphere
+	cfg.Events = true
+	e, err := newEndpoint(&cfg, s.URL, slog.Default(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.events.poll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	collector := e.events.collector.Reference()
+
+	c, err := govmomi.NewClient(ctx, s.URL, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := event.NewManager(c.Client).PostEvent(ctx, &types.BadUsernameSessionEvent{}); err != nil {
+		t.Fatal(err)
+	}
+	failed := errors.New("sink unavailable")
+	e.events.handlers = append(e.events.handlers, func(context.Context, []types.BaseEvent) error {
+		return failed
+	})
+	if err := e.events.poll(ctx); !errors.Is(err, failed) {
+		t.Fatalf("got error %v, want %v", err, failed)
+	}
+	if e.events.collector != nil {
+		t.Error("the history collector is kept after a handler failed")
+	}
+	// Collectors live in the session that created them.
+	client, err := e.clientFactory.GetClient(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := history.NewCollector(client.Client.Client, collector).Reset(ctx); err == nil {
+		t.Error("the history collector isn't destroyed after a handler failed")
+	}
+}
+
+func TestEntityKind(t *testing.T) {
+	tests := map[string]string{
+		"VirtualMachine":                 "vm",
+		"HostSystem":                     "host",
+		"DistributedVirtualPortgroup":    "network",
+		"VmwareDistributedVirtualSwitch": "dvs",
+		"ClusterComputeResource":         "cluster",
+		"StoragePod":                     "datastore_cluster",
+		"ResourcePool":                   "resource_pool",
+		"Folder":                         "folder",
+	}
+	for typ, want := range tests {
+		if got := entityKind(&types.ManagedObjectReference{Type: typ, Value: "obj-1"}); got != want {
+			t.Errorf("%s: got %s, want %s", typ, got, want)
+		}
+	}
+	if got := entityKind(nil); got != "none" {
+		t.Errorf("got %s for no entity, want none", got)
+	}
+}
@@ -40,16 +40,66 @@ func NewExporter(logger *slog.Logger, cfg *Config) (*Exporter, error) {
 	if cfg.CollectConcurrency > 0 {
 		defaultVSphere.CollectConcurrency = cfg.CollectConcurrency
 	}
+	defaultVSphere.IncludePaths = make(map[string][]string, len(defaultConfig.IncludePaths))
+	for kind, paths := range defaultConfig.IncludePaths {
+		if p := cfg.IncludePaths[kind]; len(p) > 0 {
+			paths = p
+		}
+		defaultVSphere.IncludePaths[kind] = paths
+	}
+	defaultVSphere.ExcludePaths = copyKindLists(cfg.ExcludePaths)
+	defaultVSphere.IncludeNames = copyKindLists(cfg.IncludeNames)
+	defaultVSphere.ExcludeNames = copyKindLists(cfg.ExcludeNames)
+	defaultVSphere.IncludeAttributes = copyKindLists(cfg.IncludeAttributes)
+	defaultVSphere.ExcludeAttributes = copyKindLists(cfg.ExcludeAttributes)
+	defaultVSphere.IncludeTags = copyKindLists(cfg.IncludeTags)
+	defaultVSphere.ExcludeTags = copyKindLists(cfg.ExcludeTags)
+	defaultVSphere.DisableDatastoreClusters = cfg.DisableDatastoreClusters
+	defaultVSphere.DisableResourcePools = cfg.DisableResourcePools
+	defaultVSphere.DisableVApps = cfg.DisableVApps
+	defaultVSphere.DisableNetworks = cfg.DisableNetworks
+	defaultVSphere.DisableDVSwitches = cfg.DisableDVSwitches
+	defaultVSphere.DisableDVPortgroups = cfg.DisableDVPortgroups
+	defaultVSphere.VMPowerStates = cfg.VMPowerStates
+	defaultVSphere.VMIncludeGuests = cfg.VMIncludeGuests
+	defaultVSphere.VMExcludeGuests = cfg.VMExcludeGuests
+	defaultVSphere.VMIncludeTemplates = cfg.VMIncludeTemplates
+	defaultVSphere.HostSensors = cfg.HostSensors
+	defaultVSphere.VMSnapshotInfo = cfg.VMSnapshotInfo
+	defaultVSphere.CustomAttributes = cfg.CustomAttributes
+	defaultVSphere.AttributeLabels = cfg.AttributeLabels
+	defaultVSphere.TagCategories = cfg.TagCategories
+	defaultVSphere.TagLabels = cfg.TagLabels
+	defaultVSphere.InventoryPath = cfg.InventoryPath
+	defaultVSphere.InventoryPathLabels = cfg.InventoryPathLabels
+	defaultVSphere.Alarms = cfg.Alarms
+	defaultVSphere.Tasks = cfg.Tasks
+	defaultVSphere.Sessions = cfg.Sessions
+	defaultVSphere.Licenses = cfg.Licenses
+	defaultVSphere.Events = cfg.Events
+	defaultVSphere.EventsForward = cfg.EventsForward
+	defaultVSphere.EventsForwardTarget = cfg.EventsForwardTarget
+	defaultVSphere.EventsCheckpointFile = cfg.EventsCheckpointFile
+	if cfg.EventsInterval > 0 {
+		defaultVSphere.EventsInterval = cfg.EventsInterval
+	}
+	defaultVSphere.VMKubernetesNodeKey = cfg.VMKubernetesNodeKey
 
-	var e *endpoint
+	var (
+		e   *endpoint
+		err error
+	)
 	if cfg.EnableExporterMetrics {
 		goCollector := collectors.NewGoCollector()
 		registry.MustRegister(goCollector)
 		buildInfoCollector := collectors.NewBuildInfoCollector()
 		registry.MustRegister(buildInfoCollector)
-		e = newEndpoint(defaultVSphere, cfg.VSphereURL, logger, registry)
+		e, err = newEndpoint(defaultVSphere, cfg.VSphereURL, logger, registry)
 	} else {
-		e = newEndpoint(defaultVSphere, cfg.VSphereURL, logger, nil)
+		e, err = newEndpoint(defaultVSphere, cfg.VSphereURL, logger, nil)
+	}
+	if err != nil {
+		return nil, err
 	}
 
 	vsphereCollector, err := newVSphereCollector(
@@ -100,6 +150,15 @@ func (e *Exporter) Start() error {
 	return web.ListenAndServe(e.server, flagConfig, e.logger.With("component", "web"))
 }
 
+// copyKindLists returns a copy of the given per resource kind lists.
+func copyKindLists(src map[string][]string) map[string][]string {
+	dst := make(map[string][]string, len(src))
+	for kind, l := range src {
+		dst[kind] = l
+	}
+	return dst
+}
+
 func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	e.metricsHandlerFunc(w, r)
 }
@@ -2,16 +2,27 @@ package vsphere
 
 import (
 	"bufio"
+	"context"
 	"crypto/tls"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"regexp"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/object"
 	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vapi/rest"
+	_ "github.com/vmware/govmomi/vapi/simulator"
+	"github.com/vmware/govmomi/vapi/tags"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
 )
 
 func createSim(folders int) (*simulator.Model, *simulator.Server, error) {
@@ -22,6 +33,9 @@ func createSim(folders int) (*simulator.Model, *simulator.Server, error) {
 	m.Cluster = 2
 	m.Host = 4
 	m.Machine = 8
+	m.Pod = 1
+	m.Pool = 1
+	m.App = 1
 
 	err := m.Create()
 	if err != nil {
@@ -29,11 +43,160 @@ func createSim(folders int) (*simulator.Model, *simulator.Server, error) {
 	}
 
 	m.Service.TLS = new(tls.Config)
+	m.Service.RegisterEndpoints = true
 
 	s := m.Service.NewServer()
 	return m, s, nil
 }
 
+// simulatorVM returns the virtual machine of the simulator with the given name.
+func simulatorVM(name string) (*simulator.VirtualMachine, error) {
+	for _, vm := range simulator.Map.All("VirtualMachine") {
+		if vm.(mo.Entity).Entity().Name == name {
+			return vm.(*simulator.VirtualMachine), nil
+		}
+	}
+	return nil, fmt.Errorf("virtual machine %s not found", name)
+}
+
+// tagVM attaches the tag payments of the category Application to the virtual machine DC0_H0_VM0 of the simulator.
+func tagVM(s *simulator.Server) error {
+	ctx := context.Background()
+	c, err := govmomi.NewClient(ctx, s.URL, true)
+	if err != nil {
+		return err
+	}
+	rc := rest.NewClient(c.Client)
+	if err := rc.Login(ctx, s.URL.User); err != nil {
+		return err
+	}
+	m := tags.NewManager(rc)
+	category, err := m.CreateCategory(ctx, &tags.Category{Name: "Application", Cardinality: "SINGLE"})
+	if err != nil {
+		return err
+	}
+	tag, err := m.CreateTag(ctx, &tags.Tag{Name: "payments", CategoryID: category})
+	if err != nil {
+		return err
+	}
+	vm, err := simulatorVM("DC0_H0_VM0")
+	if err != nil {
+		return err
+	}
+	return m.AttachTag(ctx, tag, vm.Reference())
+}
+
+// setOwner defines the custom attribute Owner and sets it to payments on the virtual machine DC0_H0_VM2 and to ops on
+// the host DC0_H0 of the simulator.
+func setOwner(s *simulator.Server) error {
+	ctx := context.Background()
+	c, err := govmomi.NewClient(ctx, s.URL, true)
+	if err != nil {
+		return err
+	}
+	m, err := object.GetCustomFieldsManager(c.Client)
+	if err != nil {
+		return err
+	}
+	def, err := m.Add(ctx, "Owner", "", nil, nil)
+	if err != nil {
+		return err
+	}
+	vm, err := simulatorVM("DC0_H0_VM2")
+	if err != nil {
+		return err
+	}
+	if err := m.Set(ctx, vm.Reference(), def.Key, "payments"); err != nil {
+		return err
+	}
+	for _, host := range simulator.Map.All("HostSystem") {
+		if host.(mo.Entity).Entity().Name == "DC0_H0" {
+			return m.Set(ctx, host.Reference(), def.Key, "ops")
+		}
+	}
+	return fmt.Errorf("host DC0_H0 not found")
+}
+
+// setKubernetesNode writes the Kubernetes node name Worker-1 to the guestinfo.k8s.node extraConfig key of the
+// virtual machine DC0_C0_RP1_VM0 of the simulator and returns the UUID of the virtual machine.
+func setKubernetesNode(s *simulator.Server) (string, error) {
+	ctx := context.Background()
+	c, err := govmomi.NewClient(ctx, s.URL, true)
+	if err != nil {
+		return "", err
+	}
+	vm, err := simulatorVM("DC0_C0_RP1_VM0")
+	if err != nil {
+		return "", err
+	}
+	task, err := object.NewVirtualMachine(c.Client, vm.Reference()).Reconfigure(ctx, types.VirtualMachineConfigSpec{
+		ExtraConfig: []types.BaseOptionValue{&types.OptionValue{Key: "guestinfo.k8s.node", Value: "Worker-1"}},
+	})
+	if err != nil {
+		return "", err
+	}
+	if err := task.Wait(ctx); err != nil {
+		return "", err
+	}
+	return vm.Config.Uuid, nil
+}
+
+// triggerAlarm adds a red alarm on a virtual machine to the triggered alarm state of the root folder. The simulator
+// has no alarm manager, so the alarm is registered by hand.
+func triggerAlarm(s *simulator.Server) error {
+	c, err := govmomi.NewClient(context.Background(), s.URL, true)
+	if err != nil {
+		return err
+	}
+	alarm := &mo.Alarm{
+		ExtensibleManagedObject: mo.ExtensibleManagedObject{
+			Self: types.ManagedObjectReference{Type: "Alarm", Value: "alarm-1"},
+		},
+		Info: types.AlarmInfo{AlarmSpec: types.AlarmSpec{Name: "Virtual machine CPU usage"}},
+	}
+	simulator.Map.Put(alarm)
+	vm := simulator.Map.Any("VirtualMachine").(*simulator.VirtualMachine)
+	root := simulator.Map.Get(c.ServiceContent.RootFolder).(*simulator.Folder)
+	root.TriggeredAlarmState = append(root.TriggeredAlarmState, types.AlarmState{
+		Key:           "alarm-1." + vm.Self.Value,
+		Entity:        vm.Self,
+		Alarm:         alarm.Self,
+		OverallStatus: types.ManagedEntityStatusRed,
+		Time:          time.Unix(1700000000, 0),
+	})
+	return nil
+}
+
+// setAdmissionControlPolicy sets a HA admission control policy tolerating two host failures on the cluster DC0_C0
+// of the simulator, which doesn't configure any.
+func setAdmissionControlPolicy() error {
+	for _, obj := range simulator.Map.All("ClusterComputeResource") {
+		cluster := obj.(*simulator.ClusterComputeResource)
+		if cluster.Name == "DC0_C0" {
+			cfg := cluster.ConfigurationEx.(*types.ClusterConfigInfoEx)
+			cfg.DasConfig.AdmissionControlPolicy = &types.ClusterFailoverResourcesAdmissionControlPolicy{FailoverLevel: 2}
+			return nil
+		}
+	}
+	return fmt.Errorf("cluster DC0_C0 not found")
+}
+
+// runTask adds a virtual machine clone that has been running for an hour to the recent tasks of the task manager.
+func runTask() {
+	vm := simulator.Map.Any("VirtualMachine").Reference()
+	start := time.Now().Add(-time.Hour)
+	simulator.Map.Put(&mo.Task{
+		Info: types.TaskInfo{
+			Key:           "task-clone",
+			DescriptionId: "VirtualMachine.clone",
+			Entity:        &vm,
+			State:         types.TaskInfoStateRunning,
+			QueueTime:     start,
+			StartTime:     &start,
+		},
+	})
+}
+
 func TestExporter(t *testing.T) {
 	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
 		Level:     slog.LevelDebug,
@@ -46,6 +209,22 @@ func TestExporter(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
+	if err := tagVM(s); err != nil {
+		t.Fatal(err)
+	}
+	if err := triggerAlarm(s); err != nil {
+		t.Fatal(err)
+	}
+	if err := setOwner(s); err != nil {
+		t.Fatal(err)
+	}
+	nodeUUID, err := setKubernetesNode(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := setAdmissionControlPolicy(); err != nil {
+		t.Fatal(err)
+	}
 
 	type args struct {
 		logger *slog.Logger
@@ -54,6 +233,11 @@ func TestExporter(t *testing.T) {
 	tests := []struct {
 		name string
 		args args
+		// metrics expected on top of the ones in test_metrics.txt
+		metrics []string
+		// series expected as a metric name followed by label name and value pairs,
+		// matched regardless of the order of the labels
+		series [][]string
 	}{
 		{
 			name: "test exporter",
@@ -83,6 +267,151 @@ func TestExporter(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "test exporter - kubernetes node key",
+			args: args{
+				logger: logger,
+				cfg: &Config{
+					TelemetryPath:           "/metrics",
+					VSphereURL:              s.URL,
+					TLSConfigPath:           "",
+					ChunkSize:               256,
+					ObjectDiscoveryInterval: 0,
+					EnableExporterMetrics:   false,
+					VMKubernetesNodeKey:     "guestinfo.k8s.node",
+				},
+			},
+			series: [][]string{
+				{"vsphere_vm_kubernetes_node_info", "name", "DC0_C0_RP1_VM0", "node", "worker-1",
+					"provider_id", "vsphere://" + strings.ToLower(nodeUUID)},
+			},
+		},
+		{
+			name: "test exporter - host sensors",
+			args: args{
+				logger: logger,
+				cfg: &Config{
+					TelemetryPath:           "/metrics",
+					VSphereURL:              s.URL,
+					TLSConfigPath:           "",
+					ChunkSize:               256,
+					ObjectDiscoveryInterval: 0,
+					EnableExporterMetrics:   false,
+					HostSensors:             true,
+				},
+			},
+			metrics: []string{
+				`vsphere_host_sensor_value{`,
+				`vsphere_host_sensor_status{`,
+			},
+		},
+		{
+			name: "test exporter - custom attributes",
+			args: args{
+				logger: logger,
+				cfg: &Config{
+					TelemetryPath:           "/metrics",
+					VSphereURL:              s.URL,
+					TLSConfigPath:           "",
+					ChunkSize:               256,
+					ObjectDiscoveryInterval: 0,
+					EnableExporterMetrics:   false,
+					CustomAttributes:        []string{"Owner"},
+				},
+			},
+			series: [][]string{
+				{"vsphere_vm_custom_attribute_info", "name", "DC0_H0_VM2", "attribute_owner", "payments"},
+				{"vsphere_host_custom_attribute_info", "name", "DC0_H0", "attribute_owner", "ops"},
+				{"vsphere_vm_custom_attribute_info", "name", "DC0_H0_VM0", "attribute_owner", ""},
+			},
+		},
+		{
+			name: "test exporter - custom attributes on all series",
+			args: args{
+				logger: logger,
+				cfg: &Config{
+					TelemetryPath:           "/metrics",
+					VSphereURL:              s.URL,
+					TLSConfigPath:           "",
+					ChunkSize:               256,
+					ObjectDiscoveryInterval: 0,
+					EnableExporterMetrics:   false,
+					CustomAttributes:        []string{"Owner"},
+					AttributeLabels:         true,
+				},
+			},
+			series: [][]string{
+				{"vsphere_vm_power_state", "name", "DC0_H0_VM2", "attribute_owner", "payments"},
+				{"vsphere_VirtualMachine_cpu_usage_average", "name", "DC0_H0_VM2", "attribute_owner", "payments"},
+				{"vsphere_host_power_state", "name", "DC0_H0", "attribute_owner", "ops"},
+				{"vsphere_host_power_state", "name", "DC0_C0_H0", "attribute_owner", ""},
+			},
+		},
+		{
+			name: "test exporter - tags",
+			args: args{
+				logger: logger,
+				cfg: &Config{
+					TelemetryPath:           "/metrics",
+					VSphereURL:              s.URL,
+					TLSConfigPath:           "",
+					ChunkSize:               256,
+					ObjectDiscoveryInterval: 0,
+					EnableExporterMetrics:   false,
+					IncludeTags:             map[string][]string{"vm": {"Application=payments"}},
+					TagCategories:           []string{"Application"},
+				},
+			},
+			series: [][]string{
+				{"vsphere_vm_tag_info", "name", "DC0_H0_VM0", "tag_application", "payments"},
+			},
+			metrics: []string{
+				`vsphere_host_tag_info{`,
+			},
+		},
+		{
+			name: "test exporter - inventory path",
+			args: args{
+				logger: logger,
+				cfg: &Config{
+					TelemetryPath:           "/metrics",
+					VSphereURL:              s.URL,
+					TLSConfigPath:           "",
+					ChunkSize:               256,
+					ObjectDiscoveryInterval: 0,
+					EnableExporterMetrics:   false,
+					InventoryPath:           true,
+					InventoryPathLabels:     true,
+				},
+			},
+			metrics: []string{
+				`vsphere_vm_inventory_path_info{folder="/DC0/vm",host="DC0_H0",inventory_path="/DC0/vm/DC0_H0_VM0"`,
+				`vsphere_host_inventory_path_info{cluster="DC0_C0",datacenter="DC0",folder="/DC0/host/DC0_C0"`,
+				`vsphere_vm_power_state{folder="/DC0/vm",`,
+			},
+		},
+		{
+			name: "test exporter - alarms",
+			args: args{
+				logger: logger,
+				cfg: &Config{
+					TelemetryPath:           "/metrics",
+					VSphereURL:              s.URL,
+					TLSConfigPath:           "",
+					ChunkSize:               256,
+					ObjectDiscoveryInterval: 0,
+					EnableExporterMetrics:   false,
+					Alarms:                  true,
+				},
+			},
+			metrics: []string{
+				`vsphere_alarm_triggered{alarm="Virtual machine CPU usage",entity="DC`,
+				`vsphere_alarm_acknowledged{alarm="Virtual machine CPU usage",`,
+				`vsphere_alarm_triggered_timestamp_seconds{alarm="Virtual machine CPU usage",entity="`,
+				`",entity_type="vm",status="red"} 1`,
+				`status="red"} 1.7e+09`,
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -119,6 +448,436 @@ func TestExporter(t *testing.T) {
 				}
 			}
 			_ = f.Close()
+
+			for _, m := range tt.metrics {
+				if !strings.Contains(allMetrics, m) {
+					t.Errorf("Expected metrics to contain '%s'", m)
+				}
+			}
+			for _, series := range tt.series {
+				if !hasSeries(allMetrics, series[0], series[1:]...) {
+					t.Errorf("Expected metrics to contain a %s series with labels %v", series[0], series[1:])
+				}
+			}
+		})
+	}
+}
+
+func TestExporterPoweredOffVM(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level:     slog.LevelDebug,
+		AddSource: true,
+	}))
+
+	m, s, err := createSim(0)
+	defer m.Remove()
+	defer s.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	c, err := govmomi.NewClient(ctx, s.URL, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vm, err := simulatorVM("DC0_H0_VM1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	task, err := object.NewVirtualMachine(c.Client, vm.Reference()).PowerOff(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := task.Wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	allMetrics := scrapeExporter(t, logger, &Config{
+		TelemetryPath:           "/metrics",
+		VSphereURL:              s.URL,
+		ChunkSize:               256,
+		ObjectDiscoveryInterval: 0,
+	})
+
+	poweredOff := false
+	for _, line := range strings.Split(allMetrics, "\n") {
+		name, _, ok := strings.Cut(line, "{")
+		if ok && strings.HasPrefix(name, "vsphere_VirtualMachine_") && hasSeries(line, name, "name", "DC0_H0_VM1") {
+			t.Errorf("Expected no performance metrics for the powered off virtual machine, got '%s'", line)
+		}
+		if hasSeries(line, "vsphere_vm_power_state", "name", "DC0_H0_VM1", "state", "poweredOff") && strings.HasSuffix(line, " 1") {
+			poweredOff = true
+		}
+	}
+	if !poweredOff {
+		t.Error("Expected the powered off virtual machine to have a poweredOff vsphere_vm_power_state series")
+	}
+	if !strings.Contains(allMetrics, "vsphere_VirtualMachine_cpu_usage_average{") {
+		t.Error("Expected performance metrics for the powered on virtual machines")
+	}
+}
+
+func TestExporterDisabledKinds(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level:     slog.LevelDebug,
+		AddSource: true,
+	}))
+
+	m, s, err := createSim(0)
+	defer m.Remove()
+	defer s.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	allMetrics := scrapeExporter(t, logger, &Config{
+		TelemetryPath:            "/metrics",
+		VSphereURL:               s.URL,
+		ChunkSize:                256,
+		ObjectDiscoveryInterval:  0,
+		DisableDatastoreClusters: true,
+		DisableResourcePools:     true,
+		DisableVApps:             true,
+		DisableNetworks:          true,
+		DisableDVSwitches:        true,
+		DisableDVPortgroups:      true,
+	})
+
+	for _, prefix := range []string{
+		"vsphere_datastore_cluster_", "vsphere_resource_pool_", "vsphere_vapp_",
+		"vsphere_network_", "vsphere_dvs_", "vsphere_dv_portgroup_",
+	} {
+		if strings.Contains(allMetrics, "\n"+prefix) {
+			t.Errorf("Expected metrics not to contain '%s' series", prefix)
+		}
+	}
+	if !strings.Contains(allMetrics, "vsphere_datastore_capacity_bytes{") {
+		t.Error("Expected metrics to contain 'vsphere_datastore_capacity_bytes{'")
+	}
+}
+
+func TestExporterTagErrors(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level:     slog.LevelDebug,
+		AddSource: true,
+	}))
+
+	// Tags can't be read without the vAPI endpoints of the simulator.
+	m := simulator.VPX()
+	defer m.Remove()
+	if err := m.Create(); err != nil {
+		t.Fatal(err)
+	}
+	m.Service.TLS = new(tls.Config)
+	s := m.Service.NewServer()
+	defer s.Close()
+
+	allMetrics := scrapeExporter(t, logger, &Config{
+		TelemetryPath:           "/metrics",
+		VSphereURL:              s.URL,
+		ChunkSize:               256,
+		ObjectDiscoveryInterval: 0,
+		TagCategories:           []string{"Application"},
+	})
+	if !strings.Contains(allMetrics, "vsphere_vm_power_state{") {
+		t.Error("Expected objects to be discovered without their tags")
+	}
+	if strings.Contains(allMetrics, "vsphere_vm_tag_info{") {
+		t.Error("Expected metrics not to contain 'vsphere_vm_tag_info{'")
+	}
+
+	allMetrics = scrapeExporter(t, logger, &Config{
+		TelemetryPath:           "/metrics",
+		VSphereURL:              s.URL,
+		ChunkSize:               256,
+		ObjectDiscoveryInterval: 0,
+		IncludeTags:             map[string][]string{"vm": {"Application=payments"}},
+	})
+	if strings.Contains(allMetrics, "vsphere_vm_power_state{") {
+		t.Error("Expected virtual machines not to be discovered when their tag filter can't be evaluated")
+	}
+}
+
+func TestExporterPoolPaths(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level:     slog.LevelDebug,
+		AddSource: true,
+	}))
+
+	m, s, err := createSim(0)
+	defer m.Remove()
+	defer s.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The root resource pools are excluded from the resource_pool kind, but still appear in the paths.
+	allMetrics := scrapeExporter(t, logger, &Config{
+		TelemetryPath:           "/metrics",
+		VSphereURL:              s.URL,
+		ChunkSize:               256,
+		ObjectDiscoveryInterval: 0,
+		ExcludePaths:            map[string][]string{"resource_pool": {"/*/host/*/Resources"}},
+	})
+	if !hasSeries(allMetrics, "vsphere_resource_pool_info", "name", "DC0_C0_RP1", "path", "Resources/DC0_C0_RP1") {
+		t.Error("Expected the path of DC0_C0_RP1 to include its root resource pool")
+	}
+	if !hasSeries(allMetrics, "vsphere_vapp_info", "name", "DC0_C0_APP0", "path", "Resources/DC0_C0_APP0") {
+		t.Error("Expected the path of DC0_C0_APP0 to include its parent resource pool")
+	}
+}
+
+func TestExporterInventoryPaths(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level:     slog.LevelDebug,
+		AddSource: true,
+	}))
+
+	m, s, err := createSim(0)
+	defer m.Remove()
+	defer s.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// vCenter reports the virtual machines of a vApp without a parent folder, only with their parent vApp.
+	vm, err := simulatorVM("DC0_C0_APP0_VM0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vm.ParentVApp = vm.ResourcePool
+	vm.Parent = nil
+
+	allMetrics := scrapeExporter(t, logger, &Config{
+		TelemetryPath:           "/metrics",
+		VSphereURL:              s.URL,
+		ChunkSize:               256,
+		ObjectDiscoveryInterval: 0,
+		InventoryPath:           true,
+	})
+	if !hasSeries(allMetrics, "vsphere_vm_inventory_path_info", "name", "DC0_C0_APP0_VM0",
+		"inventory_path", "/DC0/host/DC0_C0/Resources/DC0_C0_APP0/DC0_C0_APP0_VM0") {
+		t.Error("Expected the inventory path of DC0_C0_APP0_VM0 to go through its vApp")
+	}
+
+	// A folder followed by /** selects the virtual machines labeled with that folder.
+	folders := make(map[string][]string)
+	re := regexp.MustCompile(`^vsphere_vm_inventory_path_info\{.*folder="([^"]*)".*,name="([^"]*)"`)
+	for _, line := range strings.Split(allMetrics, "\n") {
+		if match := re.FindStringSubmatch(line); match != nil {
+			folders[match[1]] = append(folders[match[1]], match[2])
+		}
+	}
+	if len(folders) == 0 {
+		t.Fatal("Expected metrics to contain 'vsphere_vm_inventory_path_info' series")
+	}
+	ctx := context.Background()
+	cli, err := newClient(ctx, logger, s.URL, defaultVSphere)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for folder, names := range folders {
+		rf := resourceFilter{
+			finder:  &finder{client: cli},
+			resType: "VirtualMachine",
+			paths:   []string{folder + "/**"},
+		}
+		var vms []mo.VirtualMachine
+		if err := rf.findAll(ctx, &vms); err != nil {
+			t.Fatal(err)
+		}
+		found := make(map[string]bool, len(vms))
+		for _, vm := range vms {
+			found[vm.Name] = true
+		}
+		for _, name := range names {
+			if !found[name] {
+				t.Errorf("Expected %s/** to select %s", folder, name)
+			}
+		}
+	}
+}
+
+func TestExporterAlarms(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level:     slog.LevelDebug,
+		AddSource: true,
+	}))
+
+	m := simulator.VPX()
+	defer m.Remove()
+	if err := m.Create(); err != nil {
+		t.Fatal(err)
+	}
+	m.Service.TLS = new(tls.Config)
+	s := m.Service.NewServer()
+	defer s.Close()
+
+	// The same alarm triggered on two virtual machines of the same name, e.g. in different folders, shares a series.
+	alarm := &mo.Alarm{
+		ExtensibleManagedObject: mo.ExtensibleManagedObject{
+			Self: types.ManagedObjectReference{Type: "Alarm", Value: "alarm-1"},
+		},
+		Info: types.AlarmInfo{AlarmSpec: types.AlarmSpec{Name: "Virtual machine CPU usage"}},
+	}
+	simulator.Map.Put(alarm)
+	root := simulator.Map.Get(m.ServiceContent.RootFolder).(*simulator.Folder)
+	for i, name := range []string{"DC0_H0_VM0", "DC0_H0_VM1"} {
+		vm, err := simulatorVM(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		vm.Name = "api-01"
+		root.TriggeredAlarmState = append(root.TriggeredAlarmState, types.AlarmState{
+			Key:           "alarm-1." + vm.Self.Value,
+			Entity:        vm.Self,
+			Alarm:         alarm.Self,
+			OverallStatus: types.ManagedEntityStatusRed,
+			Time:          time.Unix(int64(1700000000+i), 0),
+			Acknowledged:  types.NewBool(i == 0),
 		})
 	}
+
+	allMetrics := scrapeExporter(t, logger, &Config{
+		TelemetryPath:           "/metrics",
+		VSphereURL:              s.URL,
+		ChunkSize:               256,
+		ObjectDiscoveryInterval: 0,
+		Alarms:                  true,
+	})
+	want := `{alarm="Virtual machine CPU usage",entity="api-01",entity_type="vm",status="red"} `
+	for _, series := range []string{
+		"vsphere_alarm_triggered" + want + "1",
+		"vsphere_alarm_acknowledged" + want + "0",
+		"vsphere_alarm_triggered_timestamp_seconds" + want + "1.7e+09",
+	} {
+		if !strings.Contains(allMetrics, series+"\n") {
+			t.Errorf("Expected metrics to contain '%s'", series)
+		}
+	}
+}
+
+func TestExporterTasks(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level:     slog.LevelDebug,
+		AddSource: true,
+	}))
+
+	m := simulator.VPX()
+	defer m.Remove()
+	if err := m.Create(); err != nil {
+		t.Fatal(err)
+	}
+	m.Service.TLS = new(tls.Config)
+	s := m.Service.NewServer()
+	defer s.Close()
+	runTask()
+
+	allMetrics := scrapeExporter(t, logger, &Config{
+		TelemetryPath:           "/metrics",
+		VSphereURL:              s.URL,
+		ChunkSize:               256,
+		ObjectDiscoveryInterval: 0,
+		Tasks:                   true,
+	})
+	labels := []string{"entity_kind", "vm", "task_type", "VirtualMachine.clone"}
+	if v, ok := seriesValue(allMetrics, "vsphere_tasks", append(labels, "state", "running")...); !ok || v != 1 {
+		t.Errorf("got %v running clones, want 1", v)
+	}
+	// The task has been running for an hour, give or take the time of the scrape.
+	v, ok := seriesValue(allMetrics, "vsphere_tasks_oldest_running_age_seconds", labels...)
+	if !ok || v < 3600 || v > 3660 {
+		t.Errorf("got oldest running clone age %v, want about 3600", v)
+	}
+}
+
+func TestExporterSessionsAndLicenses(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level:     slog.LevelDebug,
+		AddSource: true,
+	}))
+
+	m := simulator.VPX()
+	defer m.Remove()
+	if err := m.Create(); err != nil {
+		t.Fatal(err)
+	}
+	m.Service.TLS = new(tls.Config)
+	s := m.Service.NewServer()
+	defer s.Close()
+
+	// A session of another client next to the one of the exporter.
+	if _, err := govmomi.NewClient(context.Background(), s.URL, true); err != nil {
+		t.Fatal(err)
+	}
+
+	allMetrics := scrapeExporter(t, logger, &Config{
+		TelemetryPath:           "/metrics",
+		VSphereURL:              s.URL,
+		ChunkSize:               256,
+		ObjectDiscoveryInterval: 0,
+		Sessions:                true,
+		Licenses:                true,
+	})
+	if v, ok := seriesValue(allMetrics, "vsphere_vcenter_sessions"); !ok || v != 2 {
+		t.Errorf("got %v sessions, want 2", v)
+	}
+	for _, m := range []string{
+		`vsphere_license_capacity{cost_unit="",edition="eval",license="Evaluation Mode"} 0`,
+		`vsphere_license_used{cost_unit="",edition="eval",license="Evaluation Mode"} 0`,
+	} {
+		if !strings.Contains(allMetrics, m) {
+			t.Errorf("Expected metrics to contain '%s'", m)
+		}
+	}
+}
+
+// scrapeExporter creates an exporter with the given configuration and returns the metrics it exposes.
+func scrapeExporter(t *testing.T, logger *slog.Logger, cfg *Config) string {
+	e, err := NewExporter(logger, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := http.NewRequest("GET", "/metrics", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	e.server.Handler.ServeHTTP(rr, req)
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	return rr.Body.String()
+}
+
+// hasSeries reports whether metrics contains a series of the metric name
+// with all the given label name and value pairs.
+func hasSeries(metrics, name string, labels ...string) bool {
+	_, ok := seriesValue(metrics, name, labels...)
+	return ok
+}
+
+// seriesValue returns the value of the first series of the metric name with
+// all the given label name and value pairs.
+func seriesValue(metrics, name string, labels ...string) (float64, bool) {
+	for _, line := range strings.Split(metrics, "\n") {
+		if !strings.HasPrefix(line, name+"{") && !strings.HasPrefix(line, name+" ") {
+			continue
+		}
+		found := true
+		for i := 0; i+1 < len(labels); i += 2 {
+			pair := labels[i] + "=" + strconv.Quote(labels[i+1])
+			if !strings.Contains(line, "{"+pair) && !strings.Contains(line, ","+pair) {
+				found = false
+				break
+			}
+		}
+		if !found {
+			continue
+		}
+		v, err := strconv.ParseFloat(line[strings.LastIndex(line, " ")+1:], 64)
+		return v, err == nil
+	}
+	return 0, false
 }
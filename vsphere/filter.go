@@ -0,0 +1,190 @@
+package vsphere
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// objectFilter selects discovered objects by name, custom attribute values and tags. The power state and guest OS
+// selectors only apply to virtual machines.
+type objectFilter struct {
+	includeNames      []*regexp.Regexp
+	excludeNames      []*regexp.Regexp
+	includeAttributes []attributeMatch
+	excludeAttributes []attributeMatch
+	includeTags       []attributeMatch
+	excludeTags       []attributeMatch
+	powerStates       map[string]bool
+	includeGuests     []*regexp.Regexp
+	excludeGuests     []*regexp.Regexp
+}
+
+// attributeMatch matches a custom attribute by name and exact value.
+type attributeMatch struct {
+	name  string
+	value string
+}
+
+// newObjectFilter builds the filter for the given resource kind from the configuration.
+func newObjectFilter(kind string, cfg *vSphereConfig) (*objectFilter, error) {
+	var (
+		f   objectFilter
+		err error
+	)
+	if f.includeNames, err = compileAll(cfg.IncludeNames[kind]); err != nil {
+		return nil, fmt.Errorf("invalid %s include name: %w", kind, err)
+	}
+	if f.excludeNames, err = compileAll(cfg.ExcludeNames[kind]); err != nil {
+		return nil, fmt.Errorf("invalid %s exclude name: %w", kind, err)
+	}
+	if f.includeAttributes, err = parseAttributeMatches(cfg.IncludeAttributes[kind]); err != nil {
+		return nil, fmt.Errorf("invalid %s include attribute: %w", kind, err)
+	}
+	if f.excludeAttributes, err = parseAttributeMatches(cfg.ExcludeAttributes[kind]); err != nil {
+		return nil, fmt.Errorf("invalid %s exclude attribute: %w", kind, err)
+	}
+	if f.includeTags, err = parseAttributeMatches(cfg.IncludeTags[kind]); err != nil {
+		return nil, fmt.Errorf("invalid %s include tag: %w", kind, err)
+	}
+	if f.excludeTags, err = parseAttributeMatches(cfg.ExcludeTags[kind]); err != nil {
+		return nil, fmt.Errorf("invalid %s exclude tag: %w", kind, err)
+	}
+	if kind != "vm" {
+		return &f, nil
+	}
+
+	if len(cfg.VMPowerStates) > 0 {
+		f.powerStates = make(map[string]bool, len(cfg.VMPowerStates))
+		for _, s := range cfg.VMPowerStates {
+			f.powerStates[s] = true
+		}
+	}
+	if f.includeGuests, err = compileAll(cfg.VMIncludeGuests); err != nil {
+		return nil, fmt.Errorf("invalid vm include guest: %w", err)
+	}
+	if f.excludeGuests, err = compileAll(cfg.VMExcludeGuests); err != nil {
+		return nil, fmt.Errorf("invalid vm exclude guest: %w", err)
+	}
+	return &f, nil
+}
+
+// match reports whether an object with the given name and custom attributes passes the filter.
+func (f *objectFilter) match(name string, attrs map[string]string) bool {
+	if f == nil {
+		return true
+	}
+	if len(f.includeNames) > 0 && !matchAny(f.includeNames, name) {
+		return false
+	}
+	if matchAny(f.excludeNames, name) {
+		return false
+	}
+	if len(f.includeAttributes) > 0 && !matchAttributes(f.includeAttributes, attrs) {
+		return false
+	}
+	return !matchAttributes(f.excludeAttributes, attrs)
+}
+
+// matchVM is like match, but also checks the power state and guest ID of a virtual machine.
+func (f *objectFilter) matchVM(name string, attrs map[string]string, powerState, guestID string) bool {
+	if f == nil {
+		return true
+	}
+	if f.powerStates != nil && !f.powerStates[powerState] {
+		return false
+	}
+	if len(f.includeGuests) > 0 && !matchAny(f.includeGuests, guestID) {
+		return false
+	}
+	if matchAny(f.excludeGuests, guestID) {
+		return false
+	}
+	return f.match(name, attrs)
+}
+
+// selectsTags reports whether the filter needs the tags of an object.
+func (f *objectFilter) selectsTags() bool {
+	return f != nil && (len(f.includeTags) > 0 || len(f.excludeTags) > 0)
+}
+
+// matchTags reports whether an object with the given tags, keyed by category name, passes the tag selectors.
+func (f *objectFilter) matchTags(tags map[string][]string) bool {
+	if f == nil {
+		return true
+	}
+	if len(f.includeTags) > 0 && !matchTags(f.includeTags, tags) {
+		return false
+	}
+	return !matchTags(f.excludeTags, tags)
+}
+
+// customAttributes resolves the keys of the given custom values to their field names.
+func customAttributes(names map[int32]string, values []types.BaseCustomFieldValue) map[string]string {
+	attrs := make(map[string]string, len(values))
+	for _, v := range values {
+		sv, ok := v.(*types.CustomFieldStringValue)
+		if !ok {
+			continue
+		}
+		if name, ok := names[sv.Key]; ok {
+			attrs[name] = sv.Value
+		}
+	}
+	return attrs
+}
+
+func compileAll(exprs []string) ([]*regexp.Regexp, error) {
+	res := make([]*regexp.Regexp, 0, len(exprs))
+	for _, expr := range exprs {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, re)
+	}
+	return res, nil
+}
+
+func parseAttributeMatches(pairs []string) ([]attributeMatch, error) {
+	res := make([]attributeMatch, 0, len(pairs))
+	for _, p := range pairs {
+		name, value, ok := strings.Cut(p, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("%q is not of the form name=value", p)
+		}
+		res = append(res, attributeMatch{name: name, value: value})
+	}
+	return res, nil
+}
+
+func matchAny(res []*regexp.Regexp, s string) bool {
+	for _, re := range res {
+		if re.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchAttributes(matches []attributeMatch, attrs map[string]string) bool {
+	for _, m := range matches {
+		if v, ok := attrs[m.name]; ok && v == m.value {
+			return true
+		}
+	}
+	return false
+}
+
+func matchTags(matches []attributeMatch, tags map[string][]string) bool {
+	for _, m := range matches {
+		for _, name := range tags[m.name] {
+			if name == m.value {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,93 @@
+package vsphere
+
+import (
+	"testing"
+)
+
+func TestObjectFilter(t *testing.T) {
+	cfg := &vSphereConfig{
+		IncludeNames:      map[string][]string{"vm": {"^app-"}},
+		ExcludeNames:      map[string][]string{"vm": {"^vCLS-", "-old$"}},
+		ExcludeAttributes: map[string][]string{"vm": {"monitoring=off"}},
+		VMPowerStates:     []string{"poweredOn", "suspended"},
+		VMExcludeGuests:   []string{"^windows"},
+	}
+	f, err := newObjectFilter("vm", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		attrs      map[string]string
+		powerState string
+		guestID    string
+		want       bool
+	}{
+		{name: "app-01", powerState: "poweredOn", guestID: "rhel8_64Guest", want: true},
+		{name: "app-02", powerState: "suspended", guestID: "rhel8_64Guest", want: true},
+		{name: "db-01", powerState: "poweredOn", guestID: "rhel8_64Guest", want: false},
+		{name: "app-01-old", powerState: "poweredOn", guestID: "rhel8_64Guest", want: false},
+		{name: "app-03", powerState: "poweredOff", guestID: "rhel8_64Guest", want: false},
+		{name: "app-04", powerState: "poweredOn", guestID: "windows2019srv_64Guest", want: false},
+		{name: "app-05", attrs: map[string]string{"monitoring": "off"}, powerState: "poweredOn", want: false},
+		{name: "app-06", attrs: map[string]string{"monitoring": "on"}, powerState: "poweredOn", want: true},
+	}
+	for _, tt := range tests {
+		if got := f.matchVM(tt.name, tt.attrs, tt.powerState, tt.guestID); got != tt.want {
+			t.Errorf("matchVM(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	// The VM specific selectors are ignored for other kinds.
+	hf, err := newObjectFilter("host", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hf.match("esx-01", nil) {
+		t.Errorf("host filter should match everything")
+	}
+
+	cfg.ExcludeAttributes["host"] = []string{"monitoring"}
+	if _, err := newObjectFilter("host", cfg); err == nil {
+		t.Errorf("expected error for malformed attribute")
+	}
+}
+
+func TestObjectFilterTags(t *testing.T) {
+	cfg := &vSphereConfig{
+		IncludeTags: map[string][]string{"vm": {"Application=payments", "Application=billing"}},
+		ExcludeTags: map[string][]string{"vm": {"Monitoring=off"}},
+	}
+	f, err := newObjectFilter("vm", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !f.selectsTags() {
+		t.Fatal("filter should select tags")
+	}
+
+	tests := []struct {
+		tags map[string][]string
+		want bool
+	}{
+		{tags: map[string][]string{"Application": {"payments"}}, want: true},
+		{tags: map[string][]string{"Application": {"billing", "search"}}, want: true},
+		{tags: map[string][]string{"Application": {"search"}}, want: false},
+		{tags: map[string][]string{"Application": {"payments"}, "Monitoring": {"off"}}, want: false},
+		{tags: nil, want: false},
+	}
+	for _, tt := range tests {
+		if got := f.matchTags(tt.tags); got != tt.want {
+			t.Errorf("matchTags(%v) = %v, want %v", tt.tags, got, tt.want)
+		}
+	}
+
+	hf, err := newObjectFilter("host", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hf.selectsTags() || !hf.matchTags(nil) {
+		t.Errorf("host filter should not select tags")
+	}
+}
@@ -24,15 +24,37 @@ var (
 			"ComputeResource",
 			"ClusterComputeResource",
 			"Datastore",
+			"StoragePod",
+			"Network",
+			"OpaqueNetwork",
+			"DistributedVirtualPortgroup",
+			"DistributedVirtualSwitch",
 		},
+		"StoragePod":   {"Datastore"},
+		"ResourcePool": {"ResourcePool", "VirtualApp"},
+		"VirtualApp":   {"ResourcePool", "VirtualApp"},
 	}
 	addFields = map[string][]string{
-		"HostSystem": {"parent", "summary.customValue", "customValue"},
-		"VirtualMachine": {"runtime.host", "config.guestId", "config.uuid", "runtime.powerState",
-			"summary.customValue", "guest.net", "guest.hostName", "customValue"},
-		"Datastore":              {"parent", "info", "customValue"},
-		"ClusterComputeResource": {"parent", "customValue"},
+		"HostSystem": {"parent", "summary.customValue", "customValue", "runtime.connectionState", "runtime.powerState",
+			"runtime.inMaintenanceMode", "runtime.bootTime", "overallStatus", "configIssue", "summary.rebootRequired",
+			"summary.config.product", "summary.hardware", "config.network.pnic", "config.network.vswitch",
+			"config.network.proxySwitch"},
+		"VirtualMachine": {"runtime.host", "config.guestId", "config.uuid", "config.instanceUuid", "config.template",
+			"runtime.powerState", "summary.customValue", "guest.net", "guest.hostName", "guest.ipAddress",
+			"guest.toolsVersion", "customValue", "resourcePool", "parentVApp", "snapshot", "layoutEx",
+			"runtime.consolidationNeeded", "guest.toolsRunningStatus", "guest.toolsVersionStatus2", "guest.guestState",
+			"guestHeartbeatStatus", "guest.disk", "config.hardware", "config.version", "config.cpuHotAddEnabled",
+			"config.memoryHotAddEnabled", "resourceConfig", "storage", "network"},
+		"Datastore":              {"parent", "info", "customValue", "summary", "host", "vm"},
+		"ClusterComputeResource": {"parent", "customValue", "configurationEx", "summary", "overallStatus"},
 		"Datacenter":             {"parent", "customValue"},
+		"StoragePod":             {"parent", "customValue", "summary", "podStorageDrsEntry", "childEntity"},
+		"ResourcePool":           {"parent", "customValue", "owner", "config", "summary"},
+		"VirtualApp":             {"parent", "customValue", "owner", "config", "summary"},
+
+		"Network":                     {"parent", "customValue", "summary", "vm", "host"},
+		"DistributedVirtualPortgroup": {"parent", "customValue", "summary", "vm", "host", "key", "config"},
+		"DistributedVirtualSwitch":    {"parent", "customValue", "summary", "config", "runtime", "overallStatus", "portgroup"},
 	}
 	containers = map[string]interface{}{
 		"HostSystem":      nil,
@@ -41,11 +63,13 @@ var (
 		"ResourcePool":    nil,
 		"Folder":          nil,
 		"VirtualApp":      nil,
+		"StoragePod":      nil,
 	}
 )
 
 type finder struct {
 	client *client
+	fields []string // Properties to retrieve on top of addFields
 }
 
 // ResourceFilter is a convenience class holding a finder and a set of paths. It is useful when you need a
@@ -55,6 +79,7 @@ type resourceFilter struct {
 	resType      string
 	paths        []string
 	excludePaths []string
+	objectFilter *objectFilter
 }
 
 // findAll finds all resources matching the paths that were specified upon creation of
@@ -111,6 +136,7 @@ func (f *finder) descend(ctx context.Context, root types.ManagedObjectReference,
 		if af, ok := addFields[resType]; ok {
 			fields = append(fields, af...)
 		}
+		fields = append(fields, f.fields...)
 		if recurse {
 			// Special case: The last token is a recursive wildcard, so we can grab everything
 			// recursively in a single call.
@@ -0,0 +1,81 @@
+package vsphere
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/vmware/govmomi/vim25/mo"
+)
+
+func TestFinderIncludeExcludePaths(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+	m, s, err := createSim(0)
+	defer m.Remove()
+	defer s.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	cli, err := newClient(ctx, logger, s.URL, defaultVSphere)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		paths        []string
+		excludePaths []string
+		want         func(string) bool
+		wantCount    int
+	}{
+		{
+			name:      "all vms",
+			paths:     []string{"/*/vm/**"},
+			want:      func(string) bool { return true },
+			wantCount: 128,
+		},
+		{
+			name:      "single datacenter",
+			paths:     []string{"/DC0/vm/**"},
+			want:      func(n string) bool { return strings.HasPrefix(n, "DC0_") },
+			wantCount: 64,
+		},
+		{
+			name:         "excluded vms",
+			paths:        []string{"/*/vm/**"},
+			excludePaths: []string{"/*/vm/*_H0_*", "/DC1/vm/**"},
+			want: func(n string) bool {
+				return strings.HasPrefix(n, "DC0_") && !strings.Contains(n, "_H0_")
+			},
+			wantCount: 56,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rf := resourceFilter{
+				finder:       &finder{client: cli},
+				resType:      "VirtualMachine",
+				paths:        tt.paths,
+				excludePaths: tt.excludePaths,
+			}
+			var vms []mo.VirtualMachine
+			if err := rf.findAll(ctx, &vms); err != nil {
+				t.Fatal(err)
+			}
+			if len(vms) != tt.wantCount {
+				t.Errorf("found %d vms, want %d", len(vms), tt.wantCount)
+			}
+			for _, vm := range vms {
+				if !tt.want(vm.Name) {
+					t.Errorf("unexpected vm %q", vm.Name)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,216 @@
+package vsphere
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// eventRecord is a vCenter event as it is forwarded.
+type eventRecord struct {
+	Key        int32     `json:"key"`
+	Time       time.Time `json:"time"`
+	EventType  string    `json:"event_type"`
+	VCenter    string    `json:"vcenter"`
+	Datacenter string    `json:"datacenter,omitempty"`
+	Cluster    string    `json:"cluster,omitempty"`
+	EntityKind string    `json:"entity_kind"`
+	Entity     string    `json:"entity,omitempty"`
+	User       string    `json:"user,omitempty"`
+	Message    string    `json:"message"`
+
+	// Set for alarm status changes.
+	Alarm      string `json:"alarm,omitempty"`
+	FromStatus string `json:"from_status,omitempty"`
+	ToStatus   string `json:"to_status,omitempty"`
+}
+
+func newEventRecord(vcenter string, ev types.BaseEvent) eventRecord {
+	e := ev.GetEvent()
+	kind, entity := eventEntity(ev)
+	r := eventRecord{
+		Key:        e.Key,
+		Time:       e.CreatedTime,
+		EventType:  eventType(ev),
+		VCenter:    vcenter,
+		EntityKind: kind,
+		Entity:     entity,
+		User:       e.UserName,
+		Message:    e.FullFormattedMessage,
+	}
+	if e.Datacenter != nil {
+		r.Datacenter = e.Datacenter.Name
+	}
+	if e.ComputeResource != nil {
+		r.Cluster = e.ComputeResource.Name
+	}
+	if a, ok := ev.(*types.AlarmStatusChangedEvent); ok {
+		r.Alarm = a.Alarm.Name
+		r.FromStatus = a.From
+		r.ToStatus = a.To
+		if r.Entity == "" {
+			r.Entity = a.Entity.Name
+		}
+	}
+	return r
+}
+
+// eventSink delivers forwarded events.
+type eventSink interface {
+	send(ctx context.Context, records []eventRecord) error
+}
+
+// newEventSink returns the sink of the given type: loki, cloudevents or file. The target is the push URL for the
+// first two and the path of the JSONL file for the last one.
+func newEventSink(kind, target string, timeout time.Duration) (eventSink, error) {
+	if target == "" {
+		return nil, fmt.Errorf("no target set to forward events to %s", kind)
+	}
+	client := &http.Client{Timeout: timeout}
+	switch kind {
+	case "loki":
+		return &lokiSink{url: target, client: client}, nil
+	case "cloudevents":
+		return &cloudEventsSink{url: target, client: client}, nil
+	case "file":
+		return &fileSink{path: target}, nil
+	}
+	return nil, fmt.Errorf("unknown event forwarding type %q", kind)
+}
+
+// forwarder returns the event handler sending events to the sink.
+func forwarder(vcenter string, sink eventSink) eventHandler {
+	return func(ctx context.Context, events []types.BaseEvent) error {
+		records := make([]eventRecord, 0, len(events))
+		for _, ev := range events {
+			records = append(records, newEventRecord(vcenter, ev))
+		}
+		return sink.send(ctx, records)
+	}
+}
+
+// lokiSink pushes events to the Loki push API, one stream per label set.
+type lokiSink struct {
+	url    string
+	client *http.Client
+}
+
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+func (s *lokiSink) send(ctx context.Context, records []eventRecord) error {
+	var streams []*lokiStream
+	byLabels := make(map[[5]string]*lokiStream)
+	for _, r := range records {
+		key := [5]string{r.VCenter, r.Datacenter, r.Cluster, r.Entity, r.EventType}
+		st, ok := byLabels[key]
+		if !ok {
+			st = &lokiStream{Stream: map[string]string{
+				"job":        "vmware_exporter",
+				"vcenter":    r.VCenter,
+				"datacenter": r.Datacenter,
+				"cluster":    r.Cluster,
+				"entity":     r.Entity,
+				"event_type": r.EventType,
+			}}
+			byLabels[key] = st
+			streams = append(streams, st)
+		}
+		line, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		st.Values = append(st.Values, [2]string{strconv.FormatInt(r.Time.UnixNano(), 10), string(line)})
+	}
+	return postJSON(ctx, s.client, s.url, "application/json", map[string]interface{}{"streams": streams})
+}
+
+// cloudEventsSink posts events to a webhook as a batch of CloudEvents in structured JSON mode.
+type cloudEventsSink struct {
+	url    string
+	client *http.Client
+}
+
+type cloudEvent struct {
+	SpecVersion     string      `json:"specversion"`
+	ID              string      `json:"id"`
+	Source          string      `json:"source"`
+	Type            string      `json:"type"`
+	Subject         string      `json:"subject,omitempty"`
+	Time            time.Time   `json:"time"`
+	DataContentType string      `json:"datacontenttype"`
+	Data            eventRecord `json:"data"`
+}
+
+func (s *cloudEventsSink) send(ctx context.Context, records []eventRecord) error {
+	events := make([]cloudEvent, 0, len(records))
+	for _, r := range records {
+		events = append(events, cloudEvent{
+			SpecVersion:     "1.0",
+			ID:              r.VCenter + "/" + strconv.Itoa(int(r.Key)),
+			Source:          "vcenter://" + r.VCenter,
+			Type:            "com.vmware.vsphere." + r.EventType,
+			Subject:         r.Entity,
+			Time:            r.Time,
+			DataContentType: "application/json",
+			Data:            r,
+		})
+	}
+	return postJSON(ctx, s.client, s.url, "application/cloudevents-batch+json", events)
+}
+
+// fileSink appends events to a file, one JSON object per line.
+type fileSink struct {
+	path string
+}
+
+func (s *fileSink) send(_ context.Context, records []eventRecord) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, r := range records {
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+	}
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func postJSON(ctx context.Context, client *http.Client, url, contentType string, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("posting events to %s: %s: %s", url, resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
@@ -0,0 +1,302 @@
+package vsphere
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/event"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// lokiStandIn stands in for the Loki push API and records the streams pushed to it. The first failures pushes are
+// rejected.
+type lokiStandIn struct {
+	mux      sync.Mutex
+	failures int
+	streams  []lokiStream
+}
+
+func (l *lokiStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	if l.failures > 0 {
+		l.failures--
+		http.Error(w, "ingester unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	var body struct {
+		Streams []lokiStream `json:"streams"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	l.streams = append(l.streams, body.Streams...)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// powerOffs returns the VmPoweredOffEvent lines received.
+func (l *lokiStandIn) powerOffs(t *testing.T) []eventRecord {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	var records []eventRecord
+	for _, st := range l.streams {
+		if st.Stream["event_type"] != "VmPoweredOffEvent" {
+			continue
+		}
+		for _, v := range st.Values {
+			var r eventRecord
+			if err := json.Unmarshal([]byte(v[1]), &r); err != nil {
+				t.Fatal(err)
+			}
+			records = append(records, r)
+		}
+	}
+	return records
+}
+
+func TestEventForwarding(t *testing.T) {
+	m, s, err := createSim(0)
+	defer m.Remove()
+	defer s.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loki := &lokiStandIn{}
+	ls := httptest.NewServer(loki)
+	defer ls.Close()
+
+	ctx := context.Background()
+	cfg := *defaultVSphere
+	cfg.EventsForward = "loki"
+	cfg.EventsForwardTarget = ls.URL + "/loki/api/v1/push"
+	cfg.EventsCheckpointFile = filepath.Join(t.TempDir(), "checkpoint.json")
+	e, err := newEndpoint(&cfg, s.URL, slog.Default(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.eventsForward.poll(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := govmomi.NewClient(ctx, s.URL, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	em := event.NewManager(c.Client)
+	vm := simulator.Map.Any("VirtualMachine").(*simulator.VirtualMachine)
+	powerOff := func() {
+		err := em.PostEvent(ctx, &types.VmPoweredOffEvent{VmEvent: types.VmEvent{Event: types.Event{
+			Datacenter:      &types.DatacenterEventArgument{EntityEventArgument: types.EntityEventArgument{Name: "DC0"}},
+			ComputeResource: &types.ComputeResourceEventArgument{EntityEventArgument: types.EntityEventArgument{Name: "DC0_C0"}},
+			Vm:              &types.VmEventArgument{EntityEventArgument: types.EntityEventArgument{Name: vm.Name}, Vm: vm.Self},
+		}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	powerOff()
+
+	// The next push is rejected, so the events are delivered on the following poll.
+	loki.mux.Lock()
+	loki.failures = 1
+	loki.mux.Unlock()
+	if err := e.eventsForward.poll(ctx); err == nil {
+		t.Fatal("expected an error from the rejected push")
+	}
+	if err := e.eventsForward.poll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	records := loki.powerOffs(t)
+	if len(records) != 1 {
+		t.Fatalf("got %d power off events, want 1", len(records))
+	}
+	r := records[0]
+	if r.Datacenter != "DC0" || r.Cluster != "DC0_C0" || r.Entity != vm.Name || r.EntityKind != "vm" {
+		t.Errorf("unexpected event record %+v", r)
+	}
+
+	// A new follower resumes from the checkpoint without forwarding the event again.
+	e2, err := newEndpoint(&cfg, s.URL, slog.Default(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	powerOff()
+	if err := e2.eventsForward.poll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	records = loki.powerOffs(t)
+	if len(records) != 2 || records[1].Key <= records[0].Key {
+		t.Errorf("got %+v, want the second power off event once", records)
+	}
+}
+
+func TestEventCountingWithSinkDown(t *testing.T) {
+	m, s, err := createSim(0)
+	defer m.Remove()
+	defer s.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loki := &lokiStandIn{}
+	ls := httptest.NewServer(loki)
+	defer ls.Close()
+
+	ctx := context.Background()
+	cfg := *defaultVSphere
+	cfg.Events = true
+	cfg.EventsForward = "loki"
+	cfg.EventsForwardTarget = ls.URL + "/loki/api/v1/push"
+	e, err := newEndpoint(&cfg, s.URL, slog.Default(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	poll := func() error {
+		if err := e.events.poll(ctx); err != nil {
+			t.Fatal(err)
+		}
+		return e.eventsForward.poll(ctx)
+	}
+	if err := poll(); err != nil {
+		t.Fatal(err)
+	}
+	powerOffs := func() float64 {
+		ch := make(chan prometheus.Metric, 10)
+		e.events.collect(ch)
+		close(ch)
+		var n float64
+		for metric := range ch {
+			var pb dto.Metric
+			if err := metric.Write(&pb); err != nil {
+				t.Fatal(err)
+			}
+			for _, l := range pb.Label {
+				if l.GetName() == "event_type" && l.GetValue() == "VmPoweredOffEvent" {
+					n += pb.Counter.GetValue()
+				}
+			}
+		}
+		return n
+	}
+
+	c, err := govmomi.NewClient(ctx, s.URL, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	em := event.NewManager(c.Client)
+	vm := simulator.Map.Any("VirtualMachine").(*simulator.VirtualMachine)
+	powerOff := func() {
+		err := em.PostEvent(ctx, &types.VmPoweredOffEvent{VmEvent: types.VmEvent{Event: types.Event{
+			Vm: &types.VmEventArgument{EntityEventArgument: types.EntityEventArgument{Name: vm.Name}, Vm: vm.Self},
+		}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Every push is rejected while Loki is down, but the counters move with every poll.
+	loki.mux.Lock()
+	loki.failures = 100
+	loki.mux.Unlock()
+	for i := 1; i <= 2; i++ {
+		powerOff()
+		if err := poll(); err == nil {
+			t.Fatal("expected an error from the rejected push")
+		}
+		if n := powerOffs(); n != float64(i) {
+			t.Errorf("got %v power off events counted while the sink is down, want %d", n, i)
+		}
+	}
+
+	// Once Loki is back, the backlog is forwarded without being counted again.
+	loki.mux.Lock()
+	loki.failures = 0
+	loki.mux.Unlock()
+	if err := poll(); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(loki.powerOffs(t)); n != 2 {
+		t.Errorf("got %d power off events forwarded, want 2", n)
+	}
+	if n := powerOffs(); n != 2 {
+		t.Errorf("got %v power off events counted after the sink recovered, want 2", n)
+	}
+}
+
+func TestEventSinks(t *testing.T) {
+	ctx := context.Background()
+	records := []eventRecord{
+		{Key: 1, Time: time.Unix(1700000000, 0).UTC(), EventType: "VmMigratedEvent", VCenter: "vc", Entity: "vm-1"},
+		{Key: 2, Time: time.Unix(1700000001, 0).UTC(), EventType: "AlarmStatusChangedEvent", VCenter: "vc",
+			Alarm: "Host CPU usage", FromStatus: "green", ToStatus: "red"},
+	}
+
+	var got []cloudEvent
+	hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ct := r.Header.Get("Content-Type"); ct != "application/cloudevents-batch+json" {
+			t.Errorf("unexpected content type %q", ct)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer hs.Close()
+	sink, err := newEventSink("cloudevents", hs.URL, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.send(ctx, records); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].ID != "vc/1" || got[1].Type != "com.vmware.vsphere.AlarmStatusChangedEvent" ||
+		got[1].Data.ToStatus != "red" {
+		t.Errorf("unexpected cloud events %+v", got)
+	}
+
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	sink, err = newEventSink("file", path, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range records {
+		if err := sink.send(ctx, records[i:i+1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var lines []eventRecord
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var r eventRecord
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, r)
+	}
+	if len(lines) != 2 || lines[1].Alarm != "Host CPU usage" {
+		t.Errorf("unexpected file contents %+v", lines)
+	}
+
+	if _, err := newEventSink("kafka", "localhost:9092", time.Second); err == nil {
+		t.Error("expected an error for an unknown sink")
+	}
+}
@@ -0,0 +1,126 @@
+package vsphere
+
+import (
+	"context"
+	"path"
+
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// inventoryEntity is the name and parent of a managed entity, used to build inventory paths.
+type inventoryEntity struct {
+	name   string
+	parent *types.ManagedObjectReference
+}
+
+// setInventoryPaths stores the inventory path of the given objects, e.g. /DC1/vm/Prod/Payments/api-01.
+func (e *endpoint) setInventoryPaths(ctx context.Context, client *client, objects objectMap) error {
+	refs := make([]types.ManagedObjectReference, 0, len(objects))
+	for _, obj := range objects {
+		refs = append(refs, obj.ref)
+	}
+	if err := e.loadInventory(ctx, client, refs); err != nil {
+		return err
+	}
+	for _, obj := range objects {
+		obj.inventoryPath = inventoryPath(e.inventoryCache, obj.ref.Value)
+	}
+	return nil
+}
+
+// loadInventory fetches the names and parents of the given entities and all their ancestors into the inventory
+// cache of the current discovery, keyed by moid. They are fetched in batches, and ancestors shared by many objects
+// are only retrieved once. Ancestors are looked up regardless of the include and exclude paths.
+func (e *endpoint) loadInventory(ctx context.Context, client *client, refs []types.ManagedObjectReference) error {
+	var pending []types.ManagedObjectReference
+	for _, ref := range refs {
+		if _, ok := e.inventoryCache[ref.Value]; !ok {
+			pending = append(pending, ref)
+		}
+	}
+	pc := property.DefaultCollector(client.Client.Client)
+	for len(pending) > 0 {
+		// Virtual machines in a vApp have no parent folder, only a parent vApp.
+		var vmRefs, refs []types.ManagedObjectReference
+		for _, ref := range pending {
+			if ref.Type == "VirtualMachine" {
+				vmRefs = append(vmRefs, ref)
+			} else {
+				refs = append(refs, ref)
+			}
+		}
+		var vms []mo.VirtualMachine
+		var entities []mo.ManagedEntity
+		ctx1, cancel1 := context.WithTimeout(ctx, e.cfg.Timeout)
+		var err error
+		if len(vmRefs) > 0 {
+			err = pc.Retrieve(ctx1, vmRefs, []string{"name", "parent", "parentVApp"}, &vms)
+		}
+		if err == nil && len(refs) > 0 {
+			err = pc.Retrieve(ctx1, refs, []string{"name", "parent"}, &entities)
+		}
+		cancel1()
+		if err != nil {
+			return err
+		}
+		for _, vm := range vms {
+			parent := vm.Parent
+			if parent == nil {
+				parent = vm.ParentVApp
+			}
+			entities = append(entities, mo.ManagedEntity{
+				ExtensibleManagedObject: vm.ExtensibleManagedObject,
+				Name:                    vm.Name,
+				Parent:                  parent,
+			})
+		}
+
+		// Look up the parents that haven't been seen yet in the next round.
+		pending = pending[:0]
+		seen := make(map[string]bool)
+		for _, me := range entities {
+			e.inventoryCache[me.Self.Value] = inventoryEntity{name: me.Name, parent: me.Parent}
+		}
+		for _, me := range entities {
+			if p := me.Parent; p != nil && !seen[p.Value] {
+				if _, ok := e.inventoryCache[p.Value]; !ok {
+					seen[p.Value] = true
+					pending = append(pending, *p)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// inventoryPath joins the names of an entity and its ancestors. The root folder is left out, as in the paths matched
+// by the include and exclude path globs.
+func inventoryPath(cache map[string]inventoryEntity, moid string) string {
+	var names []string
+	for {
+		ent, ok := cache[moid]
+		if !ok || ent.parent == nil {
+			break
+		}
+		names = append(names, ent.name)
+		moid = ent.parent.Value
+	}
+	if len(names) == 0 {
+		return ""
+	}
+	p := ""
+	for i := len(names) - 1; i >= 0; i-- {
+		p += "/" + names[i]
+	}
+	return p
+}
+
+// inventoryFolder returns the path of the folder holding the object with the given inventory path.
+func inventoryFolder(p string) string {
+	if p == "" {
+		return ""
+	}
+	return path.Dir(p)
+}
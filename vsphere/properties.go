@@ -0,0 +1,1246 @@
+package vsphere
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// propertySample is a metric value read from the properties of a managed object during discovery. It is
+// exported as vsphere_<kind>_<name> along with the labels identifying the object.
+type propertySample struct {
+	name   string
+	help   string
+	labels prometheus.Labels
+	value  float64
+}
+
+// vmInfoSample returns an info sample carrying the identity of a virtual machine and its guest.
+func vmInfoSample(r *mo.VirtualMachine, primaryIPs map[string]string) propertySample {
+	labels := prometheus.Labels{
+		"guest_id":         "",
+		"uuid":             "",
+		"instance_uuid":    "",
+		"guest_hostname":   "",
+		"ipv4":             primaryIPs["ipv4"],
+		"ipv6":             primaryIPs["ipv6"],
+		"tools_version":    "",
+		"hardware_version": "",
+	}
+	if r.Config != nil {
+		labels["guest_id"] = r.Config.GuestId
+		labels["uuid"] = r.Config.Uuid
+		labels["instance_uuid"] = r.Config.InstanceUuid
+		labels["hardware_version"] = r.Config.Version
+	}
+	if r.Guest != nil {
+		labels["guest_hostname"] = r.Guest.HostName
+		labels["tools_version"] = r.Guest.ToolsVersion
+		// Fall back to the address reported by VMware Tools when no NIC has one.
+		if labels["ipv4"] == "" && isIPv4.MatchString(r.Guest.IpAddress) {
+			labels["ipv4"] = r.Guest.IpAddress
+		}
+	}
+	return propertySample{
+		name:   "info",
+		help:   "Information about the virtual machine and its guest.",
+		labels: labels,
+		value:  1,
+	}
+}
+
+// vmGuestSamples returns the VMware Tools, guest OS and heartbeat status of a virtual machine.
+func vmGuestSamples(r *mo.VirtualMachine) []propertySample {
+	var runningStatus, versionStatus, guestState string
+	if r.Guest != nil {
+		runningStatus = r.Guest.ToolsRunningStatus
+		versionStatus = r.Guest.ToolsVersionStatus2
+		guestState = r.Guest.GuestState
+	}
+	var samples []propertySample
+	samples = append(samples, stateSetSamples("tools_running_status", "Running status of VMware Tools in the guest.",
+		"status", toolsRunningStatuses, runningStatus)...)
+	samples = append(samples, stateSetSamples("tools_version_status", "Version status of VMware Tools in the guest.",
+		"status", toolsVersionStatuses, versionStatus)...)
+	samples = append(samples, stateSetSamples("guest_state", "Operation mode of the guest operating system.",
+		"state", guestStates, guestState)...)
+	samples = append(samples, stateSetSamples("guest_heartbeat_status", "Guest heartbeat status of the virtual machine.",
+		"status", entityStatuses, string(r.GuestHeartbeatStatus))...)
+	return samples
+}
+
+// vmGuestDiskSamples returns the capacity and free space of every filesystem mounted in the guest, as reported by
+// VMware Tools.
+func vmGuestDiskSamples(r *mo.VirtualMachine) []propertySample {
+	if r.Guest == nil {
+		return nil
+	}
+	samples := make([]propertySample, 0, 2*len(r.Guest.Disk))
+	seen := make(map[string]bool)
+	for _, d := range r.Guest.Disk {
+		// A filesystem spanning several virtual disks, e.g. an LVM volume, is reported once per disk.
+		if seen[d.DiskPath] {
+			continue
+		}
+		seen[d.DiskPath] = true
+		labels := prometheus.Labels{"path": d.DiskPath, "filesystem_type": d.FilesystemType}
+		samples = append(samples,
+			propertySample{
+				name:   "guest_disk_capacity_bytes",
+				help:   "Capacity of the guest filesystem in bytes.",
+				labels: labels,
+				value:  float64(d.Capacity),
+			},
+			propertySample{
+				name:   "guest_disk_free_bytes",
+				help:   "Free space of the guest filesystem in bytes.",
+				labels: labels,
+				value:  float64(d.FreeSpace),
+			},
+		)
+	}
+	return samples
+}
+
+// vmResourceSamples returns the configured CPU, memory and devices of a virtual machine along with its CPU and
+// memory allocation.
+func vmResourceSamples(r *mo.VirtualMachine) []propertySample {
+	var samples []propertySample
+	if c := r.Config; c != nil {
+		var disks, nics int
+		for _, d := range c.Hardware.Device {
+			switch d.(type) {
+			case *types.VirtualDisk:
+				disks++
+			case types.BaseVirtualEthernetCard:
+				nics++
+			}
+		}
+		samples = append(samples,
+			propertySample{
+				name:  "cpus",
+				help:  "Number of virtual CPUs of the virtual machine.",
+				value: float64(c.Hardware.NumCPU),
+			},
+			propertySample{
+				name:  "cores_per_socket",
+				help:  "Number of cores per virtual CPU socket.",
+				value: float64(c.Hardware.NumCoresPerSocket),
+			},
+			propertySample{
+				name:  "memory_bytes",
+				help:  "Configured memory of the virtual machine in bytes.",
+				value: float64(c.Hardware.MemoryMB) * 1024 * 1024,
+			},
+			propertySample{
+				name:  "cpu_hot_add_enabled",
+				help:  "Whether virtual CPUs can be added while the virtual machine is running.",
+				value: boolToFloat(c.CpuHotAddEnabled != nil && *c.CpuHotAddEnabled),
+			},
+			propertySample{
+				name:  "memory_hot_add_enabled",
+				help:  "Whether memory can be added while the virtual machine is running.",
+				value: boolToFloat(c.MemoryHotAddEnabled != nil && *c.MemoryHotAddEnabled),
+			},
+			propertySample{
+				name:  "disks",
+				help:  "Number of virtual disks of the virtual machine.",
+				value: float64(disks),
+			},
+			propertySample{
+				name:  "nics",
+				help:  "Number of virtual network adapters of the virtual machine.",
+				value: float64(nics),
+			},
+		)
+	}
+	if rc := r.ResourceConfig; rc != nil {
+		samples = append(samples, allocationSamples("cpu", "mhz", "MHz", 1, &rc.CpuAllocation)...)
+		samples = append(samples, allocationSamples("memory", "bytes", "bytes", 1024*1024, &rc.MemoryAllocation)...)
+	}
+	return samples
+}
+
+// vmStorageSamples returns the storage used by a virtual machine on each datastore. datastoreNames maps datastore
+// moids to their names.
+func vmStorageSamples(r *mo.VirtualMachine, datastoreNames map[string]string) []propertySample {
+	if r.Storage == nil {
+		return nil
+	}
+	samples := make([]propertySample, 0, 4*len(r.Storage.PerDatastoreUsage))
+	for _, u := range r.Storage.PerDatastoreUsage {
+		name, ok := datastoreNames[u.Datastore.Value]
+		if !ok {
+			name = u.Datastore.Value
+		}
+		labels := prometheus.Labels{"datastore": name}
+		samples = append(samples,
+			propertySample{
+				name:   "storage_committed_bytes",
+				help:   "Storage space used by the virtual machine on the datastore in bytes.",
+				labels: labels,
+				value:  float64(u.Committed),
+			},
+			propertySample{
+				name:   "storage_uncommitted_bytes",
+				help:   "Additional storage space the virtual machine may use on the datastore in bytes.",
+				labels: labels,
+				value:  float64(u.Uncommitted),
+			},
+			propertySample{
+				name:   "storage_unshared_bytes",
+				help:   "Storage space used exclusively by the virtual machine on the datastore in bytes.",
+				labels: labels,
+				value:  float64(u.Unshared),
+			},
+			propertySample{
+				name:   "storage_provisioned_bytes",
+				help:   "Storage space provisioned for the virtual machine on the datastore in bytes.",
+				labels: labels,
+				value:  float64(u.Committed + u.Uncommitted),
+			},
+		)
+	}
+	return samples
+}
+
+// vmKubernetesNodeSample returns an info sample with the vSphere cloud provider ID of a virtual machine and the
+// Kubernetes node it backs. The node name is taken from the guest hostname unless one is given.
+func vmKubernetesNodeSample(r *mo.VirtualMachine, node string) propertySample {
+	if node == "" && r.Guest != nil {
+		node = r.Guest.HostName
+	}
+	return propertySample{
+		name: "kubernetes_node_info",
+		help: "Kubernetes provider ID and node name of the virtual machine.",
+		labels: prometheus.Labels{
+			"provider_id": "vsphere://" + strings.ToLower(r.Config.Uuid),
+			"node":        strings.ToLower(node),
+		},
+		value: 1,
+	}
+}
+
+var (
+	hostConnectionStates = []string{
+		string(types.HostSystemConnectionStateConnected),
+		string(types.HostSystemConnectionStateNotResponding),
+		string(types.HostSystemConnectionStateDisconnected),
+	}
+	hostPowerStates = []string{
+		string(types.HostSystemPowerStatePoweredOn),
+		string(types.HostSystemPowerStatePoweredOff),
+		string(types.HostSystemPowerStateStandBy),
+		string(types.HostSystemPowerStateUnknown),
+	}
+	datastoreMaintenanceModes = []string{
+		string(types.DatastoreSummaryMaintenanceModeStateNormal),
+		string(types.DatastoreSummaryMaintenanceModeStateEnteringMaintenance),
+		string(types.DatastoreSummaryMaintenanceModeStateInMaintenance),
+	}
+	sdrsAutomationLevels = []string{
+		string(types.StorageDrsPodConfigInfoBehaviorManual),
+		string(types.StorageDrsPodConfigInfoBehaviorAutomated),
+	}
+	drsAutomationLevels = []string{
+		string(types.DrsBehaviorManual),
+		string(types.DrsBehaviorPartiallyAutomated),
+		string(types.DrsBehaviorFullyAutomated),
+	}
+	toolsRunningStatuses = []string{
+		string(types.VirtualMachineToolsRunningStatusGuestToolsRunning),
+		string(types.VirtualMachineToolsRunningStatusGuestToolsNotRunning),
+		string(types.VirtualMachineToolsRunningStatusGuestToolsExecutingScripts),
+	}
+	toolsVersionStatuses = []string{
+		string(types.VirtualMachineToolsVersionStatusGuestToolsNotInstalled),
+		string(types.VirtualMachineToolsVersionStatusGuestToolsNeedUpgrade),
+		string(types.VirtualMachineToolsVersionStatusGuestToolsCurrent),
+		string(types.VirtualMachineToolsVersionStatusGuestToolsUnmanaged),
+		string(types.VirtualMachineToolsVersionStatusGuestToolsTooOld),
+		string(types.VirtualMachineToolsVersionStatusGuestToolsSupportedOld),
+		string(types.VirtualMachineToolsVersionStatusGuestToolsSupportedNew),
+		string(types.VirtualMachineToolsVersionStatusGuestToolsTooNew),
+		string(types.VirtualMachineToolsVersionStatusGuestToolsBlacklisted),
+	}
+	guestStates = []string{
+		string(types.VirtualMachineGuestStateRunning),
+		string(types.VirtualMachineGuestStateShuttingDown),
+		string(types.VirtualMachineGuestStateResetting),
+		string(types.VirtualMachineGuestStateStandby),
+		string(types.VirtualMachineGuestStateNotRunning),
+		string(types.VirtualMachineGuestStateUnknown),
+	}
+	dvsHostMemberStates = []string{
+		string(types.DistributedVirtualSwitchHostMemberHostComponentStateUp),
+		string(types.DistributedVirtualSwitchHostMemberHostComponentStatePending),
+		string(types.DistributedVirtualSwitchHostMemberHostComponentStateOutOfSync),
+		string(types.DistributedVirtualSwitchHostMemberHostComponentStateWarning),
+		string(types.DistributedVirtualSwitchHostMemberHostComponentStateDisconnected),
+		string(types.DistributedVirtualSwitchHostMemberHostComponentStateDown),
+	}
+	entityStatuses = []string{
+		string(types.ManagedEntityStatusGray),
+		string(types.ManagedEntityStatusGreen),
+		string(types.ManagedEntityStatusYellow),
+		string(types.ManagedEntityStatusRed),
+	}
+)
+
+// hostSamples returns the runtime state and hardware inventory samples of a host.
+func hostSamples(r *mo.HostSystem) []propertySample {
+	var samples []propertySample
+	samples = append(samples, stateSetSamples("connection_state", "Connection state of the host.", "state",
+		hostConnectionStates, string(r.Runtime.ConnectionState))...)
+	samples = append(samples, stateSetSamples("power_state", "Power state of the host.", "state",
+		hostPowerStates, string(r.Runtime.PowerState))...)
+	samples = append(samples, stateSetSamples("overall_status", "Overall alarm status of the host.", "status",
+		entityStatuses, string(r.OverallStatus))...)
+	samples = append(samples,
+		propertySample{
+			name:  "maintenance_mode",
+			help:  "Whether the host is in maintenance mode.",
+			value: boolToFloat(r.Runtime.InMaintenanceMode),
+		},
+		propertySample{
+			name:  "reboot_required",
+			help:  "Whether the host requires a reboot.",
+			value: boolToFloat(r.Summary.RebootRequired),
+		},
+		propertySample{
+			name:  "config_issues",
+			help:  "Number of configuration issues of the host.",
+			value: float64(len(r.ConfigIssue)),
+		},
+	)
+	if r.Runtime.BootTime != nil {
+		samples = append(samples, propertySample{
+			name:  "boot_time_seconds",
+			help:  "Boot time of the host in seconds since the Unix epoch.",
+			value: float64(r.Runtime.BootTime.Unix()),
+		})
+	}
+
+	info := prometheus.Labels{
+		"version":   "",
+		"build":     "",
+		"vendor":    "",
+		"model":     "",
+		"cpu_model": "",
+	}
+	if p := r.Summary.Config.Product; p != nil {
+		info["version"] = p.Version
+		info["build"] = p.Build
+	}
+	if hw := r.Summary.Hardware; hw != nil {
+		info["vendor"] = hw.Vendor
+		info["model"] = hw.Model
+		info["cpu_model"] = hw.CpuModel
+		samples = append(samples,
+			propertySample{
+				name:  "cpu_sockets",
+				help:  "Number of physical CPU packages of the host.",
+				value: float64(hw.NumCpuPkgs),
+			},
+			propertySample{
+				name:  "cpu_cores",
+				help:  "Number of physical CPU cores of the host.",
+				value: float64(hw.NumCpuCores),
+			},
+			propertySample{
+				name:  "cpu_threads",
+				help:  "Number of physical CPU threads of the host.",
+				value: float64(hw.NumCpuThreads),
+			},
+			propertySample{
+				name:  "cpu_mhz",
+				help:  "Speed of the host CPU cores in MHz.",
+				value: float64(hw.CpuMhz),
+			},
+			propertySample{
+				name:  "memory_bytes",
+				help:  "Physical memory size of the host in bytes.",
+				value: float64(hw.MemorySize),
+			},
+		)
+	}
+	samples = append(samples, propertySample{
+		name:   "info",
+		help:   "ESXi version and hardware information of the host.",
+		labels: info,
+		value:  1,
+	})
+	return samples
+}
+
+// hostPNICSamples returns the link state, speed and duplex of the physical NICs of a host, labeled with the device
+// name, e.g. vmnic0. The info sample maps each NIC to the standard or distributed switch it is an uplink of, along
+// with its uplink name on a distributed switch.
+func hostPNICSamples(r *mo.HostSystem) []propertySample {
+	if r.Config == nil || r.Config.Network == nil {
+		return nil
+	}
+	type uplink struct {
+		switchName string
+		switchType string
+		name       string
+	}
+	network := r.Config.Network
+	devices := make(map[string]string, len(network.Pnic))
+	for _, nic := range network.Pnic {
+		devices[nic.Key] = nic.Device
+	}
+	uplinks := make(map[string]uplink)
+	for _, vs := range network.Vswitch {
+		for _, key := range vs.Pnic {
+			uplinks[key] = uplink{switchName: vs.Name, switchType: "vswitch"}
+		}
+	}
+	for _, ps := range network.ProxySwitch {
+		// Uplink port names are keyed by port key, which the backing maps to NIC devices.
+		portNames := make(map[string]string, len(ps.UplinkPort))
+		for _, p := range ps.UplinkPort {
+			portNames[p.Key] = p.Value
+		}
+		deviceNames := make(map[string]string)
+		if b, ok := ps.Spec.Backing.(*types.DistributedVirtualSwitchHostMemberPnicBacking); ok {
+			for _, spec := range b.PnicSpec {
+				deviceNames[spec.PnicDevice] = portNames[spec.UplinkPortKey]
+			}
+		}
+		for _, key := range ps.Pnic {
+			uplinks[key] = uplink{switchName: ps.DvsName, switchType: "dvs", name: deviceNames[devices[key]]}
+		}
+	}
+
+	samples := make([]propertySample, 0, 4*len(network.Pnic))
+	for _, nic := range network.Pnic {
+		labels := prometheus.Labels{"nic": nic.Device}
+		var (
+			speed      float64
+			fullDuplex bool
+		)
+		if nic.LinkSpeed != nil {
+			speed = float64(nic.LinkSpeed.SpeedMb)
+			fullDuplex = nic.LinkSpeed.Duplex
+		}
+		u := uplinks[nic.Key]
+		samples = append(samples,
+			propertySample{
+				name:   "pnic_link_up",
+				help:   "Whether the physical NIC has a link.",
+				labels: labels,
+				value:  boolToFloat(nic.LinkSpeed != nil),
+			},
+			propertySample{
+				name:   "pnic_link_speed_mbps",
+				help:   "Negotiated link speed of the physical NIC in megabits per second, 0 if the link is down.",
+				labels: labels,
+				value:  speed,
+			},
+			propertySample{
+				name:   "pnic_full_duplex",
+				help:   "Whether the physical NIC link is full duplex.",
+				labels: labels,
+				value:  boolToFloat(fullDuplex),
+			},
+			propertySample{
+				name: "pnic_info",
+				help: "Driver, MAC address and switch of the physical NIC.",
+				labels: prometheus.Labels{
+					"nic":         nic.Device,
+					"driver":      nic.Driver,
+					"mac":         nic.Mac,
+					"pci":         nic.Pci,
+					"switch":      u.switchName,
+					"switch_type": u.switchType,
+					"uplink":      u.name,
+				},
+				value: 1,
+			},
+		)
+	}
+	return samples
+}
+
+// hostHealthSamples returns the hardware health sensor readings and hardware status of a host. Sensor readings
+// are scaled by their unit modifier.
+func hostHealthSamples(r *mo.HostSystem) []propertySample {
+	hs := r.Runtime.HealthSystemRuntime
+	if hs == nil {
+		return nil
+	}
+	var samples []propertySample
+	if hs.SystemHealthInfo != nil {
+		seen := make(map[string]bool)
+		for _, sensor := range hs.SystemHealthInfo.NumericSensorInfo {
+			// Some hosts report the same sensor more than once.
+			if seen[sensor.SensorType+"/"+sensor.Name] {
+				continue
+			}
+			seen[sensor.SensorType+"/"+sensor.Name] = true
+			samples = append(samples, propertySample{
+				name: "sensor_value",
+				help: "Current reading of the host hardware sensor.",
+				labels: prometheus.Labels{
+					"sensor": sensor.Name,
+					"type":   sensor.SensorType,
+					"unit":   sensor.BaseUnits,
+				},
+				value: float64(sensor.CurrentReading) * math.Pow10(int(sensor.UnitModifier)),
+			})
+			if sensor.HealthState != nil {
+				samples = append(samples, propertySample{
+					name: "sensor_status",
+					help: "Health state of the host hardware sensor.",
+					labels: prometheus.Labels{
+						"sensor": sensor.Name,
+						"type":   sensor.SensorType,
+						"status": strings.ToLower(sensor.HealthState.GetElementDescription().Key),
+					},
+					value: 1,
+				})
+			}
+		}
+	}
+	if hw := hs.HardwareStatusInfo; hw != nil {
+		elements := make(map[string][]types.BaseHostHardwareElementInfo)
+		elements["memory"] = hw.MemoryStatusInfo
+		elements["cpu"] = hw.CpuStatusInfo
+		for i := range hw.StorageStatusInfo {
+			elements["storage"] = append(elements["storage"], &hw.StorageStatusInfo[i])
+		}
+		seen := make(map[string]bool)
+		for kind, infos := range elements {
+			for _, i := range infos {
+				info := i.GetHostHardwareElementInfo()
+				if info.Status == nil || seen[kind+"/"+info.Name] {
+					continue
+				}
+				seen[kind+"/"+info.Name] = true
+				samples = append(samples, propertySample{
+					name: "hardware_status",
+					help: "Health status of the host hardware component.",
+					labels: prometheus.Labels{
+						"component": info.Name,
+						"type":      kind,
+						"status":    strings.ToLower(info.Status.GetElementDescription().Key),
+					},
+					value: 1,
+				})
+			}
+		}
+	}
+	return samples
+}
+
+// clusterSamples returns the HA and DRS configuration, capacity and balance samples of a cluster.
+func clusterSamples(r *mo.ClusterComputeResource) []propertySample {
+	samples := stateSetSamples("overall_status", "Overall alarm status of the cluster.", "status",
+		entityStatuses, string(r.OverallStatus))
+
+	if cfg, ok := r.ConfigurationEx.(*types.ClusterConfigInfoEx); ok {
+		das := cfg.DasConfig
+		samples = append(samples,
+			propertySample{
+				name:  "ha_enabled",
+				help:  "Whether vSphere HA is enabled on the cluster.",
+				value: boolToFloat(das.Enabled != nil && *das.Enabled),
+			},
+			propertySample{
+				name:  "ha_admission_control_enabled",
+				help:  "Whether vSphere HA admission control is enabled on the cluster.",
+				value: boolToFloat(das.AdmissionControlEnabled != nil && *das.AdmissionControlEnabled),
+			},
+		)
+		if das.AdmissionControlPolicy != nil {
+			samples = append(samples, propertySample{
+				name:   "ha_admission_control_policy",
+				help:   "Admission control policy of the cluster.",
+				labels: prometheus.Labels{"policy": admissionControlPolicyName(das.AdmissionControlPolicy)},
+				value:  1,
+			})
+		}
+		if level, ok := haFailoverLevel(das.AdmissionControlPolicy); ok {
+			samples = append(samples, propertySample{
+				name:  "ha_failover_level",
+				help:  "Configured number of host failures the cluster tolerates.",
+				value: float64(level),
+			})
+		}
+
+		drs := cfg.DrsConfig
+		samples = append(samples, propertySample{
+			name:  "drs_enabled",
+			help:  "Whether DRS is enabled on the cluster.",
+			value: boolToFloat(drs.Enabled != nil && *drs.Enabled),
+		})
+		samples = append(samples, stateSetSamples("drs_automation_level", "DRS automation level of the cluster.",
+			"level", drsAutomationLevels, string(drs.DefaultVmBehavior))...)
+	}
+
+	if sum, ok := r.Summary.(*types.ClusterComputeResourceSummary); ok {
+		samples = append(samples,
+			propertySample{
+				name:  "cpu_total_mhz",
+				help:  "Aggregated CPU resources of all hosts in the cluster in MHz.",
+				value: float64(sum.TotalCpu),
+			},
+			propertySample{
+				name:  "cpu_effective_mhz",
+				help:  "Effective CPU resources available to virtual machines in the cluster in MHz.",
+				value: float64(sum.EffectiveCpu),
+			},
+			propertySample{
+				name:  "memory_total_bytes",
+				help:  "Aggregated memory of all hosts in the cluster in bytes.",
+				value: float64(sum.TotalMemory),
+			},
+			propertySample{
+				name:  "memory_effective_bytes",
+				help:  "Effective memory available to virtual machines in the cluster in bytes.",
+				value: float64(sum.EffectiveMemory) * 1024 * 1024,
+			},
+			propertySample{
+				name:  "hosts",
+				help:  "Number of hosts in the cluster.",
+				value: float64(sum.NumHosts),
+			},
+			propertySample{
+				name:  "effective_hosts",
+				help:  "Number of effective hosts in the cluster.",
+				value: float64(sum.NumEffectiveHosts),
+			},
+			propertySample{
+				name:  "ha_current_failover_level",
+				help:  "Current number of host failures the cluster tolerates.",
+				value: float64(sum.CurrentFailoverLevel),
+			},
+			propertySample{
+				name:  "drs_current_balance",
+				help:  "Current load imbalance of the cluster, multiplied by 1000.",
+				value: float64(sum.CurrentBalance),
+			},
+			propertySample{
+				name:  "drs_target_balance",
+				help:  "Target load imbalance of the cluster, multiplied by 1000.",
+				value: float64(sum.TargetBalance),
+			},
+		)
+		// The DRS score is only available as of vSphere 7.
+		if sum.DrsScore > 0 {
+			samples = append(samples, propertySample{
+				name:  "drs_score",
+				help:  "DRS score of the cluster in percent.",
+				value: float64(sum.DrsScore),
+			})
+		}
+	}
+	return samples
+}
+
+// admissionControlPolicyName returns a short name for the given HA admission control policy.
+func admissionControlPolicyName(p types.BaseClusterDasAdmissionControlPolicy) string {
+	switch p.(type) {
+	case *types.ClusterFailoverLevelAdmissionControlPolicy:
+		return "failover_level"
+	case *types.ClusterFailoverResourcesAdmissionControlPolicy:
+		return "failover_resources"
+	case *types.ClusterFailoverHostAdmissionControlPolicy:
+		return "failover_hosts"
+	default:
+		return "unknown"
+	}
+}
+
+// haFailoverLevel returns the number of host failures tolerated by the given HA admission control policy. The
+// failover level of the cluster configuration is deprecated in favour of the one of the policy.
+func haFailoverLevel(p types.BaseClusterDasAdmissionControlPolicy) (int32, bool) {
+	var level int32
+	switch p := p.(type) {
+	case *types.ClusterFailoverLevelAdmissionControlPolicy:
+		return p.FailoverLevel, true
+	case *types.ClusterFailoverResourcesAdmissionControlPolicy:
+		level = p.FailoverLevel
+	case *types.ClusterFailoverHostAdmissionControlPolicy:
+		level = p.FailoverLevel
+	default:
+		return 0, false
+	}
+	// The failover level of these policies defaults to 1 when unset.
+	if level == 0 {
+		level = 1
+	}
+	return level, true
+}
+
+// datastoreSamples returns the space, accessibility and mount samples of a datastore.
+func datastoreSamples(r *mo.Datastore, url string) []propertySample {
+	sum := r.Summary
+	samples := []propertySample{
+		{
+			name:  "capacity_bytes",
+			help:  "Maximum capacity of the datastore in bytes.",
+			value: float64(sum.Capacity),
+		},
+		{
+			name:  "free_bytes",
+			help:  "Available space of the datastore in bytes.",
+			value: float64(sum.FreeSpace),
+		},
+		{
+			name:  "uncommitted_bytes",
+			help:  "Additional space potentially used by thin provisioned disks on the datastore in bytes.",
+			value: float64(sum.Uncommitted),
+		},
+		{
+			name:  "provisioned_bytes",
+			help:  "Space provisioned on the datastore in bytes, including uncommitted space.",
+			value: float64(sum.Capacity - sum.FreeSpace + sum.Uncommitted),
+		},
+		{
+			name:  "accessible",
+			help:  "Whether the datastore is accessible.",
+			value: boolToFloat(sum.Accessible),
+		},
+		{
+			name:  "multiple_host_access",
+			help:  "Whether the datastore is mounted by more than one host.",
+			value: boolToFloat(sum.MultipleHostAccess != nil && *sum.MultipleHostAccess),
+		},
+		{
+			name:  "vms",
+			help:  "Number of virtual machines stored on the datastore.",
+			value: float64(len(r.Vm)),
+		},
+		{
+			name:  "hosts",
+			help:  "Number of hosts mounting the datastore.",
+			value: float64(len(r.Host)),
+		},
+		{
+			name: "info",
+			help: "Type and URL of the datastore.",
+			labels: prometheus.Labels{
+				"type": sum.Type,
+				"url":  url,
+			},
+			value: 1,
+		},
+	}
+	if sum.MaintenanceMode != "" {
+		samples = append(samples, stateSetSamples("maintenance_mode", "Maintenance mode state of the datastore.", "state",
+			datastoreMaintenanceModes, sum.MaintenanceMode)...)
+	}
+	return samples
+}
+
+// datastoreClusterSamples returns the space and Storage DRS samples of a datastore cluster.
+func datastoreClusterSamples(r *mo.StoragePod) []propertySample {
+	samples := []propertySample{
+		{
+			name:  "datastores",
+			help:  "Number of datastores in the datastore cluster.",
+			value: float64(len(r.ChildEntity)),
+		},
+	}
+	if sum := r.Summary; sum != nil {
+		samples = append(samples,
+			propertySample{
+				name:  "capacity_bytes",
+				help:  "Total capacity of the datastore cluster in bytes.",
+				value: float64(sum.Capacity),
+			},
+			propertySample{
+				name:  "free_bytes",
+				help:  "Total free space of the datastore cluster in bytes.",
+				value: float64(sum.FreeSpace),
+			},
+		)
+	}
+	if entry := r.PodStorageDrsEntry; entry != nil {
+		pc := entry.StorageDrsConfig.PodConfig
+		samples = append(samples,
+			propertySample{
+				name:  "sdrs_enabled",
+				help:  "Whether Storage DRS is enabled on the datastore cluster.",
+				value: boolToFloat(pc.Enabled),
+			},
+			propertySample{
+				name:  "sdrs_recommendations",
+				help:  "Number of pending Storage DRS recommendations of the datastore cluster.",
+				value: float64(len(entry.Recommendation)),
+			},
+		)
+		samples = append(samples, stateSetSamples("sdrs_automation_level",
+			"Storage DRS automation level of the datastore cluster.", "level", sdrsAutomationLevels,
+			pc.DefaultVmBehavior)...)
+	}
+	return samples
+}
+
+// poolSamples returns the allocation and usage samples of a resource pool or vApp.
+func poolSamples(r *mo.ResourcePool, path string) []propertySample {
+	samples := []propertySample{
+		{
+			name:   "info",
+			help:   "Path of the resource pool below the root resource pool of its cluster.",
+			labels: prometheus.Labels{"path": path},
+			value:  1,
+		},
+	}
+	samples = append(samples, allocationSamples("cpu", "mhz", "MHz", 1, &r.Config.CpuAllocation)...)
+	samples = append(samples, allocationSamples("memory", "bytes", "bytes", 1024*1024, &r.Config.MemoryAllocation)...)
+
+	if r.Summary == nil {
+		return samples
+	}
+	if qs := r.Summary.GetResourcePoolSummary().QuickStats; qs != nil {
+		samples = append(samples,
+			propertySample{
+				name:  "cpu_usage_mhz",
+				help:  "CPU used by the resource pool in MHz.",
+				value: float64(qs.OverallCpuUsage),
+			},
+			propertySample{
+				name:  "cpu_demand_mhz",
+				help:  "CPU demanded by the resource pool in MHz.",
+				value: float64(qs.OverallCpuDemand),
+			},
+			propertySample{
+				name:  "memory_guest_usage_bytes",
+				help:  "Guest memory actively used by the resource pool in bytes.",
+				value: float64(qs.GuestMemoryUsage) * 1024 * 1024,
+			},
+			propertySample{
+				name:  "memory_host_usage_bytes",
+				help:  "Host memory consumed by the resource pool in bytes.",
+				value: float64(qs.HostMemoryUsage) * 1024 * 1024,
+			},
+			propertySample{
+				name:  "memory_ballooned_bytes",
+				help:  "Memory reclaimed from the resource pool by ballooning in bytes.",
+				value: float64(qs.BalloonedMemory) * 1024 * 1024,
+			},
+			propertySample{
+				name:  "memory_swapped_bytes",
+				help:  "Memory of the resource pool swapped to disk in bytes.",
+				value: float64(qs.SwappedMemory) * 1024 * 1024,
+			},
+		)
+	}
+	return samples
+}
+
+// allocationSamples returns the reservation, limit and shares of a resource allocation. Values are multiplied by
+// scale to convert them to the given unit. A limit of -1 means unlimited and is kept as is.
+func allocationSamples(resource, unit, unitHelp string, scale float64, a *types.ResourceAllocationInfo) []propertySample {
+	if a == nil {
+		return nil
+	}
+	var samples []propertySample
+	if a.Reservation != nil {
+		samples = append(samples, propertySample{
+			name:  resource + "_reservation_" + unit,
+			help:  "Guaranteed " + resource + " allocation in " + unitHelp + ".",
+			value: float64(*a.Reservation) * scale,
+		})
+	}
+	if a.Limit != nil {
+		limit := float64(*a.Limit)
+		if limit >= 0 {
+			limit *= scale
+		}
+		samples = append(samples, propertySample{
+			name:  resource + "_limit_" + unit,
+			help:  "Upper bound of the " + resource + " allocation in " + unitHelp + ", -1 if unlimited.",
+			value: limit,
+		})
+	}
+	if a.ExpandableReservation != nil {
+		samples = append(samples, propertySample{
+			name:  resource + "_expandable_reservation",
+			help:  "Whether the " + resource + " reservation can grow beyond the specified value.",
+			value: boolToFloat(*a.ExpandableReservation),
+		})
+	}
+	if a.Shares != nil {
+		samples = append(samples, propertySample{
+			name:   resource + "_shares",
+			help:   "Number of " + resource + " shares.",
+			labels: prometheus.Labels{"level": string(a.Shares.Level)},
+			value:  float64(a.Shares.Shares),
+		})
+	}
+	return samples
+}
+
+// vmNetworkSamples returns an info sample per network a virtual machine is connected to.
+func vmNetworkSamples(r *mo.VirtualMachine, networkNames map[string]string) []propertySample {
+	samples := make([]propertySample, 0, len(r.Network))
+	for _, n := range r.Network {
+		name, ok := networkNames[n.Value]
+		if !ok {
+			name = n.Value
+		}
+		samples = append(samples, propertySample{
+			name:   "network_info",
+			help:   "Network the virtual machine is connected to.",
+			labels: prometheus.Labels{"network": name, "network_moid": n.Value},
+			value:  1,
+		})
+	}
+	return samples
+}
+
+// networkSamples returns the usage samples of a standard or opaque network. The VLAN IDs are those of the standard
+// portgroup backing the network on each of its hosts.
+func networkSamples(r *mo.Network, vlans []int32) []propertySample {
+	ids := make([]string, 0, len(vlans))
+	for _, id := range vlans {
+		ids = append(ids, strconv.Itoa(int(id)))
+	}
+	samples := []propertySample{
+		{
+			name:  "vms",
+			help:  "Number of virtual machines connected to the network.",
+			value: float64(len(r.Vm)),
+		},
+		{
+			name:  "hosts",
+			help:  "Number of hosts the network is available on.",
+			value: float64(len(r.Host)),
+		},
+		{
+			name: "info",
+			help: "Type and VLAN IDs of the network.",
+			labels: prometheus.Labels{
+				"type": r.Self.Type,
+				"vlan": strings.Join(ids, ","),
+			},
+			value: 1,
+		},
+	}
+	if r.Summary != nil {
+		samples = append(samples, propertySample{
+			name:  "accessible",
+			help:  "Whether the network is accessible by at least one host.",
+			value: boolToFloat(r.Summary.GetNetworkSummary().Accessible),
+		})
+	}
+	return samples
+}
+
+// dvPortgroupSamples returns the port usage samples of a distributed portgroup. Ports of ephemeral portgroups are
+// created when a virtual machine connects, so they have no free ports. usedPorts holds the number of connected ports
+// by portgroup key; the port usage samples are left out when the portgroup is missing from it.
+func dvPortgroupSamples(r *mo.DistributedVirtualPortgroup, usedPorts map[string]int) []propertySample {
+	c := r.Config
+	vlanType, vlan := dvPortVlan(c.DefaultPortConfig)
+	samples := []propertySample{
+		{
+			name:  "ports",
+			help:  "Number of ports of the distributed portgroup.",
+			value: float64(c.NumPorts),
+		},
+		{
+			name:  "vms",
+			help:  "Number of virtual machines connected to the distributed portgroup.",
+			value: float64(len(r.Vm)),
+		},
+		{
+			name:  "hosts",
+			help:  "Number of hosts the distributed portgroup is available on.",
+			value: float64(len(r.Host)),
+		},
+		{
+			name: "info",
+			help: "Port binding, VLAN and port auto expansion of the distributed portgroup.",
+			labels: prometheus.Labels{
+				"binding":     c.Type,
+				"vlan_type":   vlanType,
+				"vlan":        vlan,
+				"auto_expand": strconv.FormatBool(c.AutoExpand != nil && *c.AutoExpand),
+			},
+			value: 1,
+		},
+	}
+	if used, ok := usedPorts[r.Key]; ok {
+		samples = append(samples,
+			propertySample{
+				name:  "ports_used",
+				help:  "Number of connected ports of the distributed portgroup.",
+				value: float64(used),
+			},
+			propertySample{
+				name:  "ports_available",
+				help:  "Number of ports of the distributed portgroup that aren't connected.",
+				value: math.Max(float64(int(c.NumPorts)-used), 0),
+			},
+		)
+	}
+	return samples
+}
+
+// dvPortVlan returns the VLAN type (vlan, trunk or pvlan) and IDs of a distributed port setting. Trunk ranges are
+// formatted as first-last.
+func dvPortVlan(setting types.BaseDVPortSetting) (string, string) {
+	s, ok := setting.(*types.VMwareDVSPortSetting)
+	if !ok || s.Vlan == nil {
+		return "", ""
+	}
+	switch v := s.Vlan.(type) {
+	case *types.VmwareDistributedVirtualSwitchVlanIdSpec:
+		return "vlan", strconv.Itoa(int(v.VlanId))
+	case *types.VmwareDistributedVirtualSwitchTrunkVlanSpec:
+		ranges := make([]string, 0, len(v.VlanId))
+		for _, r := range v.VlanId {
+			if r.Start == r.End {
+				ranges = append(ranges, strconv.Itoa(int(r.Start)))
+			} else {
+				ranges = append(ranges, strconv.Itoa(int(r.Start))+"-"+strconv.Itoa(int(r.End)))
+			}
+		}
+		return "trunk", strings.Join(ranges, ",")
+	case *types.VmwareDistributedVirtualSwitchPvlanSpec:
+		return "pvlan", strconv.Itoa(int(v.PvlanId))
+	}
+	return "", ""
+}
+
+// dvsSamples returns the port usage, membership and health samples of a distributed switch.
+func dvsSamples(r *mo.DistributedVirtualSwitch) []propertySample {
+	sum := r.Summary
+	version := ""
+	if sum.ProductInfo != nil {
+		version = sum.ProductInfo.Version
+	}
+	samples := []propertySample{
+		{
+			name:  "ports",
+			help:  "Number of ports of the distributed switch.",
+			value: float64(sum.NumPorts),
+		},
+		{
+			name:  "hosts",
+			help:  "Number of hosts that are members of the distributed switch.",
+			value: float64(len(sum.HostMember)),
+		},
+		{
+			name:  "vms",
+			help:  "Number of virtual machines connected to the distributed switch.",
+			value: float64(len(sum.Vm)),
+		},
+		{
+			name:  "portgroups",
+			help:  "Number of portgroups of the distributed switch.",
+			value: float64(len(r.Portgroup)),
+		},
+		{
+			name:   "info",
+			help:   "Version of the distributed switch.",
+			labels: prometheus.Labels{"version": version},
+			value:  1,
+		},
+	}
+	if r.Config != nil {
+		samples = append(samples, propertySample{
+			name:  "max_ports",
+			help:  "Maximum number of ports of the distributed switch.",
+			value: float64(r.Config.GetDVSConfigInfo().MaxPorts),
+		})
+	}
+	samples = append(samples, stateSetSamples("overall_status", "Overall alarm status of the distributed switch.",
+		"status", entityStatuses, string(r.OverallStatus))...)
+	if r.Runtime != nil {
+		members := make(map[string]int, len(dvsHostMemberStates))
+		for _, h := range r.Runtime.HostMemberRuntime {
+			members[h.Status]++
+		}
+		for _, state := range dvsHostMemberStates {
+			samples = append(samples, propertySample{
+				name:   "host_members",
+				help:   "Number of host members of the distributed switch by state.",
+				labels: prometheus.Labels{"state": state},
+				value:  float64(members[state]),
+			})
+		}
+	}
+	return samples
+}
+
+// vmSnapshotSamples returns the snapshot count, oldest snapshot creation time and snapshot disk usage of a virtual
+// machine. A sample per snapshot is added when perSnapshot is set.
+func vmSnapshotSamples(r *mo.VirtualMachine, perSnapshot bool) []propertySample {
+	var (
+		samples []propertySample
+		count   int
+		oldest  time.Time
+		walk    func([]types.VirtualMachineSnapshotTree)
+	)
+	walk = func(trees []types.VirtualMachineSnapshotTree) {
+		for _, t := range trees {
+			count++
+			if oldest.IsZero() || t.CreateTime.Before(oldest) {
+				oldest = t.CreateTime
+			}
+			if perSnapshot {
+				samples = append(samples, propertySample{
+					name: "snapshot_timestamp_seconds",
+					help: "Creation time of the virtual machine snapshot in seconds since the Unix epoch.",
+					labels: prometheus.Labels{
+						"snapshot":      t.Name,
+						"snapshot_moid": t.Snapshot.Value,
+					},
+					value: float64(t.CreateTime.Unix()),
+				})
+			}
+			walk(t.ChildSnapshotList)
+		}
+	}
+	if r.Snapshot != nil {
+		walk(r.Snapshot.RootSnapshotList)
+	}
+
+	samples = append(samples,
+		propertySample{
+			name:  "snapshots",
+			help:  "Number of snapshots of the virtual machine.",
+			value: float64(count),
+		},
+		propertySample{
+			name:  "snapshot_size_bytes",
+			help:  "Disk space used by the snapshots of the virtual machine in bytes.",
+			value: float64(snapshotSize(r.LayoutEx)),
+		},
+		propertySample{
+			name:  "consolidation_needed",
+			help:  "Whether the disks of the virtual machine need to be consolidated.",
+			value: boolToFloat(r.Runtime.ConsolidationNeeded != nil && *r.Runtime.ConsolidationNeeded),
+		},
+	)
+	if count > 0 {
+		samples = append(samples, propertySample{
+			name:  "snapshot_oldest_timestamp_seconds",
+			help:  "Creation time of the oldest snapshot of the virtual machine in seconds since the Unix epoch.",
+			value: float64(oldest.Unix()),
+		})
+	}
+	return samples
+}
+
+// snapshotSize returns the size of the files and delta disks that belong to the snapshots of a virtual machine.
+func snapshotSize(layout *types.VirtualMachineFileLayoutEx) int64 {
+	if layout == nil {
+		return 0
+	}
+	files := make(map[int32]types.VirtualMachineFileLayoutExFileInfo, len(layout.File))
+	for _, f := range layout.File {
+		files[f.Key] = f
+	}
+	var size int64
+	// Length of the chain of each disk when its oldest snapshot was taken.
+	chains := make(map[int32]int)
+	for _, snapshot := range layout.Snapshot {
+		if f, ok := files[snapshot.DataKey]; ok && f.Type == string(types.VirtualMachineFileLayoutExFileTypeSnapshotData) {
+			size += f.Size
+		}
+		if f, ok := files[snapshot.MemoryKey]; ok && f.Type == string(types.VirtualMachineFileLayoutExFileTypeSnapshotMemory) {
+			size += f.Size
+		}
+		for _, d := range snapshot.Disk {
+			if n, ok := chains[d.Key]; !ok || len(d.Chain) < n {
+				chains[d.Key] = len(d.Chain)
+			}
+		}
+	}
+	// The disk units added to a chain after the oldest snapshot of the disk are delta disks created by snapshots.
+	for _, d := range layout.Disk {
+		n, ok := chains[d.Key]
+		if !ok {
+			continue
+		}
+		for i := n; i < len(d.Chain); i++ {
+			for _, key := range d.Chain[i].FileKey {
+				size += files[key].Size
+			}
+		}
+	}
+	return size
+}
+
+// attributeLabels returns a label for each allowlisted custom attribute. Attributes the object doesn't have are
+// added with an empty value so that all objects of a kind have the same label names.
+func attributeLabels(attrs map[string]string, allowlist []string) prometheus.Labels {
+	labels := make(prometheus.Labels, len(allowlist))
+	for _, name := range allowlist {
+		labels[labelName("attribute_", name)] = attrs[name]
+	}
+	return labels
+}
+
+// tagLabels returns a label per tag category holding the comma separated names of the attached tags of that
+// category.
+func tagLabels(tags map[string][]string, categories []string) prometheus.Labels {
+	labels := make(prometheus.Labels, len(categories))
+	for _, category := range categories {
+		labels[labelName("tag_", category)] = strings.Join(tags[category], ",")
+	}
+	return labels
+}
+
+// labelName turns a custom attribute or tag category name into a valid label name, e.g. "Cost Center" becomes
+// "attribute_cost_center" with the attribute_ prefix.
+func labelName(prefix, name string) string {
+	var b strings.Builder
+	b.WriteString(prefix)
+	for _, r := range strings.ToLower(name) {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune('_')
+		}
+	}
+	return b.String()
+}
+
+// checkLabelNames returns an error when two of the given custom attribute or tag category names turn into the same
+// label name, since their values would overwrite each other.
+func checkLabelNames(prefix string, names []string) error {
+	seen := make(map[string]string, len(names))
+	for _, name := range names {
+		label := labelName(prefix, name)
+		if other, ok := seen[label]; ok && other != name {
+			return fmt.Errorf("%q and %q both map to the label %s", other, name, label)
+		}
+		seen[label] = name
+	}
+	return nil
+}
+
+// stateSetSamples returns one sample per state, set to 1 for the current state and 0 for all others.
+func stateSetSamples(name, help, label string, states []string, current string) []propertySample {
+	samples := make([]propertySample, 0, len(states))
+	for _, s := range states {
+		samples = append(samples, propertySample{
+			name:   name,
+			help:   help,
+			labels: prometheus.Labels{label: s},
+			value:  boolToFloat(s == current),
+		})
+	}
+	return samples
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,340 @@
+package vsphere
+
+import (
+	"flag"
+	"reflect"
+	"testing"
+
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+func TestVMGuestDiskSamples(t *testing.T) {
+	vm := &mo.VirtualMachine{
+		Guest: &types.GuestInfo{
+			Disk: []types.GuestDiskInfo{
+				{DiskPath: "/", Capacity: 100, FreeSpace: 40, FilesystemType: "xfs",
+					Mappings: []types.GuestInfoVirtualDiskMapping{{Key: 2000}}},
+				{DiskPath: "C:\\", Capacity: 200, FreeSpace: 10, FilesystemType: "NTFS"},
+				// The same filesystem on a second virtual disk.
+				{DiskPath: "/", Capacity: 100, FreeSpace: 40, FilesystemType: "xfs",
+					Mappings: []types.GuestInfoVirtualDiskMapping{{Key: 2001}}},
+			},
+		},
+	}
+	samples := vmGuestDiskSamples(vm)
+	if len(samples) != 4 {
+		t.Fatalf("got %d samples, want 4", len(samples))
+	}
+	want := map[string]float64{
+		"guest_disk_capacity_bytes /":    100,
+		"guest_disk_free_bytes /":        40,
+		"guest_disk_capacity_bytes C:\\": 200,
+		"guest_disk_free_bytes C:\\":     10,
+	}
+	for _, s := range samples {
+		key := s.name + " " + s.labels["path"]
+		if v, ok := want[key]; !ok || v != s.value {
+			t.Errorf("unexpected sample %s = %v", key, s.value)
+		}
+	}
+
+	if samples := vmGuestDiskSamples(&mo.VirtualMachine{}); len(samples) != 0 {
+		t.Errorf("got %d samples for a VM without guest info, want 0", len(samples))
+	}
+}
+
+func TestHAFailoverLevel(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy types.BaseClusterDasAdmissionControlPolicy
+		want   int32
+		wantOK bool
+	}{
+		{"no policy", nil, 0, false},
+		{"failover level", &types.ClusterFailoverLevelAdmissionControlPolicy{FailoverLevel: 2}, 2, true},
+		{"failover resources", &types.ClusterFailoverResourcesAdmissionControlPolicy{FailoverLevel: 3}, 3, true},
+		{"failover resources default", &types.ClusterFailoverResourcesAdmissionControlPolicy{}, 1, true},
+		{"failover hosts", &types.ClusterFailoverHostAdmissionControlPolicy{FailoverLevel: 2}, 2, true},
+		{"vm monitoring", &types.ClusterDasAdmissionControlPolicy{}, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := haFailoverLevel(tt.policy)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("got %d, %v, want %d, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+
+	cluster := &mo.ClusterComputeResource{}
+	cluster.ConfigurationEx = &types.ClusterConfigInfoEx{}
+	for _, s := range clusterSamples(cluster) {
+		if s.name == "ha_failover_level" {
+			t.Errorf("got a ha_failover_level sample for a cluster without admission control policy")
+		}
+	}
+}
+
+func TestSnapshotSize(t *testing.T) {
+	files := []types.VirtualMachineFileLayoutExFileInfo{
+		{Key: 0, Type: "config", Size: 10},
+		{Key: 1, Type: "snapshotList", Size: 5},
+		{Key: 2, Type: "diskDescriptor", Size: 1},
+		{Key: 3, Type: "diskExtent", Size: 1000},
+		{Key: 4, Type: "snapshotData", Size: 20},
+		{Key: 5, Type: "snapshotMemory", Size: 300},
+		{Key: 6, Type: "diskDescriptor", Size: 1},
+		{Key: 7, Type: "diskExtent", Size: 50},
+	}
+	base := types.VirtualMachineFileLayoutExDiskUnit{FileKey: []int32{2, 3}}
+	delta := types.VirtualMachineFileLayoutExDiskUnit{FileKey: []int32{6, 7}}
+	baseDisk := []types.VirtualMachineFileLayoutExDiskLayout{{Key: 2000, Chain: []types.VirtualMachineFileLayoutExDiskUnit{base}}}
+	deltaDisk := []types.VirtualMachineFileLayoutExDiskLayout{{Key: 2000, Chain: []types.VirtualMachineFileLayoutExDiskUnit{base, delta}}}
+
+	tests := []struct {
+		name   string
+		layout *types.VirtualMachineFileLayoutEx
+		want   int64
+	}{
+		{"no layout", nil, 0},
+		{"no snapshot", &types.VirtualMachineFileLayoutEx{File: files, Disk: baseDisk}, 0},
+		// The disk of a linked clone is a delta of the disk of its parent.
+		{"linked clone", &types.VirtualMachineFileLayoutEx{File: files, Disk: deltaDisk}, 0},
+		{"snapshot", &types.VirtualMachineFileLayoutEx{
+			File: files,
+			Disk: deltaDisk,
+			Snapshot: []types.VirtualMachineFileLayoutExSnapshotLayout{
+				{DataKey: 4, MemoryKey: 5, Disk: baseDisk},
+			},
+		}, 20 + 300 + 1 + 50},
+		{"snapshot without memory", &types.VirtualMachineFileLayoutEx{
+			File: files,
+			Disk: deltaDisk,
+			Snapshot: []types.VirtualMachineFileLayoutExSnapshotLayout{
+				{DataKey: 4, MemoryKey: -1, Disk: baseDisk},
+			},
+		}, 20 + 1 + 50},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := snapshotSize(tt.layout); got != tt.want {
+				t.Errorf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAttributeLabels(t *testing.T) {
+	labels := attributeLabels(map[string]string{"Cost Center": "42", "team": "payments"}, []string{"Cost Center", "env"})
+	if len(labels) != 2 {
+		t.Fatalf("got %d labels, want 2", len(labels))
+	}
+	if v := labels["attribute_cost_center"]; v != "42" {
+		t.Errorf("attribute_cost_center = %q, want 42", v)
+	}
+	if v, ok := labels["attribute_env"]; !ok || v != "" {
+		t.Errorf("attribute_env = %q, want an empty label", v)
+	}
+}
+
+func TestCheckLabelNames(t *testing.T) {
+	if err := checkLabelNames("attribute_", []string{"Cost Center", "env", "env"}); err != nil {
+		t.Errorf("got %v for distinct label names, want no error", err)
+	}
+	if err := checkLabelNames("attribute_", []string{"Cost Center", "env", "cost-center"}); err == nil {
+		t.Error("got no error for names mapping to the same label")
+	}
+
+	var names []string
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.Var(labelListFlag{&names, "tag_"}, "tag-categories", "")
+	if err := fs.Set("tag-categories", "Environment,environment"); err == nil {
+		t.Error("got no error for tag categories mapping to the same label")
+	}
+}
+
+func TestDVPortVlan(t *testing.T) {
+	tests := []struct {
+		name     string
+		vlan     types.BaseVmwareDistributedVirtualSwitchVlanSpec
+		wantType string
+		want     string
+	}{
+		{
+			name:     "vlan",
+			vlan:     &types.VmwareDistributedVirtualSwitchVlanIdSpec{VlanId: 120},
+			wantType: "vlan",
+			want:     "120",
+		},
+		{
+			name: "trunk",
+			vlan: &types.VmwareDistributedVirtualSwitchTrunkVlanSpec{VlanId: []types.NumericRange{
+				{Start: 100, End: 199},
+				{Start: 300, End: 300},
+			}},
+			wantType: "trunk",
+			want:     "100-199,300",
+		},
+		{
+			name:     "private vlan",
+			vlan:     &types.VmwareDistributedVirtualSwitchPvlanSpec{PvlanId: 7},
+			wantType: "pvlan",
+			want:     "7",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotType, got := dvPortVlan(&types.VMwareDVSPortSetting{Vlan: tt.vlan})
+			if gotType != tt.wantType || got != tt.want {
+				t.Errorf("got %q %q, want %q %q", gotType, got, tt.wantType, tt.want)
+			}
+		})
+	}
+	if gotType, got := dvPortVlan(nil); gotType != "" || got != "" {
+		t.Errorf("got %q %q for no port setting, want empty strings", gotType, got)
+	}
+}
+
+func TestDVPortgroupSamples(t *testing.T) {
+	pg := &mo.DistributedVirtualPortgroup{
+		Key:    "dvportgroup-1",
+		Config: types.DVPortgroupConfigInfo{NumPorts: 8},
+	}
+	names := func(samples []propertySample) map[string]float64 {
+		m := make(map[string]float64)
+		for _, s := range samples {
+			m[s.name] = s.value
+		}
+		return m
+	}
+
+	got := names(dvPortgroupSamples(pg, map[string]int{"dvportgroup-1": 3}))
+	if got["ports_used"] != 3 || got["ports_available"] != 5 {
+		t.Errorf("got ports_used %v and ports_available %v, want 3 and 5", got["ports_used"], got["ports_available"])
+	}
+
+	// Ports that couldn't be fetched leave out the port usage rather than reporting it as unused.
+	got = names(dvPortgroupSamples(pg, map[string]int{}))
+	for _, name := range []string{"ports_used", "ports_available"} {
+		if _, ok := got[name]; ok {
+			t.Errorf("got %s sample without port usage", name)
+		}
+	}
+	if got["ports"] != 8 {
+		t.Errorf("got ports %v, want 8", got["ports"])
+	}
+}
+
+func TestPortgroupVlans(t *testing.T) {
+	host := func(moid string, vlans map[string]int32) mo.HostSystem {
+		h := mo.HostSystem{Config: &types.HostConfigInfo{Network: &types.HostNetworkInfo{}}}
+		h.Self = types.ManagedObjectReference{Type: "HostSystem", Value: moid}
+		for name, id := range vlans {
+			h.Config.Network.Portgroup = append(h.Config.Network.Portgroup, types.HostPortGroup{
+				Spec: types.HostPortGroupSpec{Name: name, VlanId: id},
+			})
+		}
+		return h
+	}
+	network := func(moid, typ, name string, hosts ...string) mo.Network {
+		n := mo.Network{Name: name}
+		n.Self = types.ManagedObjectReference{Type: typ, Value: moid}
+		for _, h := range hosts {
+			n.Host = append(n.Host, types.ManagedObjectReference{Type: "HostSystem", Value: h})
+		}
+		return n
+	}
+	hosts := []mo.HostSystem{
+		host("host-1", map[string]int32{"VM Network": 20, "Backup": 5}),
+		host("host-2", map[string]int32{"VM Network": 10}),
+		host("host-3", map[string]int32{"VM Network": 30}),
+	}
+	networks := []mo.Network{
+		// Same portgroup name in two datacenters.
+		network("network-1", "Network", "VM Network", "host-1", "host-2"),
+		network("network-2", "Network", "VM Network", "host-3"),
+		network("network-3", "OpaqueNetwork", "VM Network", "host-1"),
+	}
+	got := portgroupVlans(networks, hosts)
+	want := map[string][]int32{
+		"network-1": {10, 20},
+		"network-2": {30},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestHostPNICSamples(t *testing.T) {
+	host := &mo.HostSystem{
+		Config: &types.HostConfigInfo{
+			Network: &types.HostNetworkInfo{
+				Pnic: []types.PhysicalNic{
+					{Key: "key-vim.host.PhysicalNic-vmnic0", Device: "vmnic0", Driver: "ixgben", Mac: "00:50:56:00:00:01",
+						LinkSpeed: &types.PhysicalNicLinkInfo{SpeedMb: 25000, Duplex: true}},
+					{Key: "key-vim.host.PhysicalNic-vmnic1", Device: "vmnic1", Driver: "ixgben", Mac: "00:50:56:00:00:02"},
+					{Key: "key-vim.host.PhysicalNic-vmnic2", Device: "vmnic2", Driver: "ntg3", Mac: "00:50:56:00:00:03",
+						LinkSpeed: &types.PhysicalNicLinkInfo{SpeedMb: 1000, Duplex: true}},
+				},
+				Vswitch: []types.HostVirtualSwitch{
+					{Name: "vSwitch0", Pnic: []string{"key-vim.host.PhysicalNic-vmnic2"}},
+				},
+				ProxySwitch: []types.HostProxySwitch{
+					{
+						DvsName: "dvs-prod",
+						Pnic:    []string{"key-vim.host.PhysicalNic-vmnic0", "key-vim.host.PhysicalNic-vmnic1"},
+						UplinkPort: []types.KeyValue{
+							{Key: "16", Value: "uplink1"},
+							{Key: "17", Value: "uplink2"},
+						},
+						Spec: types.HostProxySwitchSpec{
+							Backing: &types.DistributedVirtualSwitchHostMemberPnicBacking{
+								PnicSpec: []types.DistributedVirtualSwitchHostMemberPnicSpec{
+									{PnicDevice: "vmnic0", UplinkPortKey: "16"},
+									{PnicDevice: "vmnic1", UplinkPortKey: "17"},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	values := make(map[string]float64)
+	infos := make(map[string]map[string]string)
+	for _, s := range hostPNICSamples(host) {
+		if s.name == "pnic_info" {
+			infos[s.labels["nic"]] = s.labels
+			continue
+		}
+		values[s.name+"/"+s.labels["nic"]] = s.value
+	}
+	wantValues := map[string]float64{
+		"pnic_link_up/vmnic0":         1,
+		"pnic_link_speed_mbps/vmnic0": 25000,
+		"pnic_full_duplex/vmnic0":     1,
+		"pnic_link_up/vmnic1":         0,
+		"pnic_link_speed_mbps/vmnic1": 0,
+		"pnic_link_up/vmnic2":         1,
+		"pnic_link_speed_mbps/vmnic2": 1000,
+	}
+	for k, want := range wantValues {
+		if got, ok := values[k]; !ok || got != want {
+			t.Errorf("%s = %v, want %v", k, got, want)
+		}
+	}
+
+	wantInfos := map[string][3]string{
+		"vmnic0": {"dvs-prod", "dvs", "uplink1"},
+		"vmnic1": {"dvs-prod", "dvs", "uplink2"},
+		"vmnic2": {"vSwitch0", "vswitch", ""},
+	}
+	for nic, want := range wantInfos {
+		l := infos[nic]
+		if got := [3]string{l["switch"], l["switch_type"], l["uplink"]}; got != want {
+			t.Errorf("%s: got switch, type and uplink %v, want %v", nic, got, want)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package vsphere
+
+import (
+	"context"
+	"sort"
+
+	"github.com/vmware/govmomi/vim25/mo"
+)
+
+// tagChunkSize is the number of objects whose tags are listed in a single vAPI request.
+const tagChunkSize = 500
+
+// tagCatalog holds the category and name of every tag defined in vCenter, keyed by tag ID.
+type tagCatalog map[string]catalogTag
+
+type catalogTag struct {
+	category string
+	name     string
+}
+
+// needsTags reports whether tags are exported or select the objects of any resource kind.
+func (e *endpoint) needsTags() bool {
+	if len(e.cfg.TagCategories) > 0 {
+		return true
+	}
+	for _, res := range e.resourceKinds {
+		if res.filter.selectsTags() {
+			return true
+		}
+	}
+	return false
+}
+
+// getTagCatalog fetches the tag categories and tags from the vAPI tagging service. It's loaded once per discovery and
+// shared by all resource kinds.
+func (e *endpoint) getTagCatalog(ctx context.Context) (tagCatalog, error) {
+	m, err := e.clientFactory.GetTagManager(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx1, cancel1 := context.WithTimeout(ctx, e.cfg.Timeout)
+	defer cancel1()
+	categories, err := m.GetCategories(ctx1)
+	if err != nil {
+		return nil, err
+	}
+	categoryNames := make(map[string]string, len(categories))
+	for _, c := range categories {
+		categoryNames[c.ID] = c.Name
+	}
+	tags, err := m.GetTags(ctx1)
+	if err != nil {
+		return nil, err
+	}
+	catalog := make(tagCatalog, len(tags))
+	for _, t := range tags {
+		catalog[t.ID] = catalogTag{category: categoryNames[t.CategoryID], name: t.Name}
+	}
+	return catalog, nil
+}
+
+// setObjectTags fetches the tags attached to the given objects from the vAPI tagging service and stores them by
+// category name.
+func (e *endpoint) setObjectTags(ctx context.Context, catalog tagCatalog, objects objectMap) error {
+	if len(objects) == 0 {
+		return nil
+	}
+	m, err := e.clientFactory.GetTagManager(ctx)
+	if err != nil {
+		return err
+	}
+
+	refs := make([]mo.Reference, 0, len(objects))
+	for _, obj := range objects {
+		obj.tags = nil
+		refs = append(refs, obj.ref)
+	}
+	for i := 0; i < len(refs); i += tagChunkSize {
+		end := i + tagChunkSize
+		if end > len(refs) {
+			end = len(refs)
+		}
+		ctx2, cancel2 := context.WithTimeout(ctx, e.cfg.Timeout)
+		attached, err := m.ListAttachedTagsOnObjects(ctx2, refs[i:end])
+		cancel2()
+		if err != nil {
+			return err
+		}
+		for _, a := range attached {
+			obj, ok := objects[a.ObjectID.Reference().Value]
+			if !ok {
+				continue
+			}
+			for _, id := range a.TagIDs {
+				tag, ok := catalog[id]
+				if !ok {
+					continue
+				}
+				if obj.tags == nil {
+					obj.tags = make(map[string][]string)
+				}
+				obj.tags[tag.category] = append(obj.tags[tag.category], tag.name)
+			}
+		}
+	}
+	for _, obj := range objects {
+		for _, names := range obj.tags {
+			sort.Strings(names)
+		}
+	}
+	return nil
+}
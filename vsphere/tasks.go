@@ -0,0 +1,245 @@
+package vsphere
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/task"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+const (
+	// taskPageSize is the number of tasks read from the history collector at once.
+	taskPageSize = 100
+	// taskOverlap is how far before the latest completed task the history collector starts when it is recreated,
+	// since tasks aren't necessarily read in completion order.
+	taskOverlap = 10 * time.Minute
+)
+
+var (
+	taskLabels = []string{"task_type", "entity_kind"}
+
+	tasksDesc = prometheus.NewDesc("vsphere_tasks",
+		"Number of recent vCenter tasks that are queued or running.",
+		[]string{"state", "task_type", "entity_kind"}, nil)
+	tasksOldestRunningDesc = prometheus.NewDesc("vsphere_tasks_oldest_running_age_seconds",
+		"Time since the oldest running task was started.", taskLabels, nil)
+	tasksCompletedDesc = prometheus.NewDesc("vsphere_tasks_completed_total",
+		"Number of vCenter tasks that completed successfully or failed.",
+		[]string{"state", "task_type", "entity_kind"}, nil)
+	taskDurationDesc = prometheus.NewDesc("vsphere_task_duration_seconds",
+		"Time from start to completion of vCenter tasks.", taskLabels, nil)
+
+	taskDurationBuckets = prometheus.ExponentialBuckets(1, 4, 8)
+)
+
+type taskKind struct {
+	taskType   string
+	entityKind string
+}
+
+type taskCount struct {
+	state string
+	taskKind
+}
+
+type taskDurations struct {
+	count   uint64
+	sum     float64
+	buckets map[float64]uint64
+}
+
+// newTaskDurations returns a duration histogram with every bucket of taskDurationBuckets.
+func newTaskDurations() *taskDurations {
+	d := &taskDurations{buckets: make(map[float64]uint64, len(taskDurationBuckets))}
+	for _, b := range taskDurationBuckets {
+		d.buckets[b] = 0
+	}
+	return d
+}
+
+// taskTracker reads the tasks completed since the exporter started from a task history collector and accumulates
+// their count and duration. Tasks are polled on every scrape.
+type taskTracker struct {
+	mux       sync.Mutex
+	collector *task.HistoryCollector
+	started   time.Time
+	// latest is the latest completion time read so far. seen holds the keys of the tasks completed within
+	// taskOverlap of it, which are read again when the collector is recreated.
+	latest    time.Time
+	seen      map[string]time.Time
+	completed map[taskCount]float64
+	durations map[taskKind]*taskDurations
+}
+
+func newTaskTracker() *taskTracker {
+	now := time.Now()
+	return &taskTracker{
+		started:   now,
+		latest:    now,
+		seen:      make(map[string]time.Time),
+		completed: make(map[taskCount]float64),
+		durations: make(map[taskKind]*taskDurations),
+	}
+}
+
+// poll reads the tasks completed since the last call.
+func (t *taskTracker) poll(ctx context.Context, cli *client) error {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	if t.collector == nil {
+		ctx1, cancel1 := context.WithTimeout(ctx, cli.Timeout)
+		defer cancel1()
+		begin := t.latest.Add(-taskOverlap)
+		spec := types.TaskFilterSpec{
+			Time: &types.TaskFilterSpecByTime{
+				TimeType:  types.TaskFilterSpecTimeOptionCompletedTime,
+				BeginTime: &begin,
+			},
+			State: []types.TaskInfoState{types.TaskInfoStateSuccess, types.TaskInfoStateError},
+		}
+		var err error
+		t.collector, err = task.NewManager(cli.Client.Client).CreateCollectorForTasks(ctx1, spec)
+		if err != nil {
+			return err
+		}
+	}
+
+	for {
+		ctx1, cancel1 := context.WithTimeout(ctx, cli.Timeout)
+		infos, err := t.collector.ReadNextTasks(ctx1, taskPageSize)
+		cancel1()
+		if err != nil {
+			// The collector goes away with the session. Start over on the next poll.
+			t.destroyCollector(ctx, cli)
+			return err
+		}
+		t.observe(infos)
+		if len(infos) < taskPageSize {
+			return nil
+		}
+	}
+}
+
+// destroyCollector removes the history collector from the server, which would otherwise keep it until the session
+// ends, so that the next poll creates a new one. It must be called with mux held.
+func (t *taskTracker) destroyCollector(ctx context.Context, cli *client) {
+	ctx1, cancel1 := context.WithTimeout(ctx, cli.Timeout)
+	defer cancel1()
+	// The collector is already gone if the session expired.
+	_ = t.collector.Destroy(ctx1)
+	t.collector = nil
+}
+
+// observe adds completed tasks to the counters and duration histograms. It must be called with mux held.
+func (t *taskTracker) observe(infos []types.TaskInfo) {
+	for _, info := range infos {
+		if info.CompleteTime == nil {
+			continue
+		}
+		// The history collector starts taskOverlap before the latest task, which may be before the tracker started.
+		if info.CompleteTime.Before(t.started) || info.CompleteTime.Before(t.latest.Add(-taskOverlap)) {
+			continue
+		}
+		if _, ok := t.seen[info.Key]; ok {
+			continue
+		}
+		t.seen[info.Key] = *info.CompleteTime
+		if info.CompleteTime.After(t.latest) {
+			t.latest = *info.CompleteTime
+		}
+
+		kind := taskKind{taskType: info.DescriptionId, entityKind: entityKind(info.Entity)}
+		t.completed[taskCount{state: string(info.State), taskKind: kind}]++
+		if info.StartTime == nil {
+			continue
+		}
+		d, ok := t.durations[kind]
+		if !ok {
+			d = newTaskDurations()
+			t.durations[kind] = d
+		}
+		seconds := info.CompleteTime.Sub(*info.StartTime).Seconds()
+		d.count++
+		d.sum += seconds
+		for _, b := range taskDurationBuckets {
+			if seconds <= b {
+				d.buckets[b]++
+			}
+		}
+	}
+	for key, completed := range t.seen {
+		if completed.Before(t.latest.Add(-taskOverlap)) {
+			delete(t.seen, key)
+		}
+	}
+}
+
+// collect sends the completed task counters and duration histograms.
+func (t *taskTracker) collect(metrics chan<- prometheus.Metric) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	for c, v := range t.completed {
+		metrics <- prometheus.MustNewConstMetric(tasksCompletedDesc, prometheus.CounterValue, v,
+			c.state, c.taskType, c.entityKind)
+	}
+	for k, d := range t.durations {
+		metrics <- prometheus.MustNewConstHistogram(taskDurationDesc, d.count, d.sum, d.buckets,
+			k.taskType, k.entityKind)
+	}
+}
+
+// collectTasks sends the number of queued and running tasks and the age of the oldest running task, taken from the
+// recent tasks of the task manager, along with the completed task metrics.
+func (c *vsphereCollector) collectTasks(ctx context.Context, metrics chan<- prometheus.Metric, cli *client,
+	now time.Time) {
+
+	if err := c.endpoint.tasks.poll(ctx, cli); err != nil {
+		c.logger.Error("error reading task history", "err", err)
+	}
+	c.endpoint.tasks.collect(metrics)
+
+	ctx1, cancel1 := context.WithTimeout(ctx, cli.Timeout)
+	defer cancel1()
+	pc := property.DefaultCollector(cli.Client.Client)
+	var tm mo.TaskManager
+	if err := pc.RetrieveOne(ctx1, *cli.Client.ServiceContent.TaskManager, []string{"recentTask"}, &tm); err != nil {
+		c.logger.Error("error getting recent tasks", "err", err)
+		return
+	}
+	var tasks []mo.Task
+	if len(tm.RecentTask) > 0 {
+		if err := pc.Retrieve(ctx1, tm.RecentTask, []string{"info"}, &tasks); err != nil {
+			c.logger.Error("error getting recent task info", "err", err)
+			return
+		}
+	}
+
+	counts := make(map[taskCount]float64)
+	oldest := make(map[taskKind]time.Time)
+	for _, tk := range tasks {
+		info := tk.Info
+		if info.State != types.TaskInfoStateQueued && info.State != types.TaskInfoStateRunning {
+			continue
+		}
+		kind := taskKind{taskType: info.DescriptionId, entityKind: entityKind(info.Entity)}
+		counts[taskCount{state: string(info.State), taskKind: kind}]++
+		if info.State == types.TaskInfoStateRunning && info.StartTime != nil {
+			if o, ok := oldest[kind]; !ok || info.StartTime.Before(o) {
+				oldest[kind] = *info.StartTime
+			}
+		}
+	}
+	for k, v := range counts {
+		metrics <- prometheus.MustNewConstMetric(tasksDesc, prometheus.GaugeValue, v, k.state, k.taskType, k.entityKind)
+	}
+	for k, start := range oldest {
+		metrics <- prometheus.MustNewConstMetric(tasksOldestRunningDesc, prometheus.GaugeValue,
+			now.Sub(start).Seconds(), k.taskType, k.entityKind)
+	}
+}
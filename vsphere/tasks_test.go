@@ -0,0 +1,103 @@
+package vsphere
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+func TestTaskTracker(t *testing.T) {
+	tr := newTaskTracker()
+	now := tr.latest
+	at := func(d time.Duration) *time.Time {
+		t := now.Add(d)
+		return &t
+	}
+	vm := &types.ManagedObjectReference{Type: "VirtualMachine", Value: "vm-1"}
+	host := &types.ManagedObjectReference{Type: "HostSystem", Value: "host-1"}
+
+	tr.observe([]types.TaskInfo{
+		{Key: "task-1", DescriptionId: "VirtualMachine.clone", Entity: vm, State: types.TaskInfoStateSuccess,
+			StartTime: at(0), CompleteTime: at(30 * time.Second)},
+		{Key: "task-2", DescriptionId: "VirtualMachine.clone", Entity: vm, State: types.TaskInfoStateError,
+			StartTime: at(0), CompleteTime: at(2 * time.Second)},
+		{Key: "task-3", DescriptionId: "HostSystem.enterMaintenanceMode", Entity: host,
+			State: types.TaskInfoStateSuccess, StartTime: at(0), CompleteTime: at(500 * time.Second)},
+		// Completed before the tracker started.
+		{Key: "task-4", DescriptionId: "VirtualMachine.clone", Entity: vm, State: types.TaskInfoStateSuccess,
+			StartTime: at(-time.Hour), CompleteTime: at(-time.Hour)},
+		{Key: "task-6", DescriptionId: "VirtualMachine.clone", Entity: vm, State: types.TaskInfoStateSuccess,
+			StartTime: at(-time.Minute), CompleteTime: at(-time.Second)},
+	})
+	// Tasks read again after the history collector is recreated aren't counted twice.
+	tr.observe([]types.TaskInfo{
+		{Key: "task-1", DescriptionId: "VirtualMachine.clone", Entity: vm, State: types.TaskInfoStateSuccess,
+			StartTime: at(0), CompleteTime: at(30 * time.Second)},
+		{Key: "task-5", DescriptionId: "Folder.createVm", State: types.TaskInfoStateSuccess,
+			StartTime: at(0), CompleteTime: at(10 * time.Second)},
+	})
+
+	ch := make(chan prometheus.Metric, 100)
+	tr.collect(ch)
+	close(ch)
+
+	counts := make(map[string]float64)
+	histograms := make(map[string]*dto.Histogram)
+	for m := range ch {
+		var pb dto.Metric
+		if err := m.Write(&pb); err != nil {
+			t.Fatal(err)
+		}
+		labels := make(map[string]string)
+		for _, l := range pb.Label {
+			labels[l.GetName()] = l.GetValue()
+		}
+		key := labels["task_type"] + "/" + labels["entity_kind"]
+		if pb.Histogram != nil {
+			histograms[key] = pb.Histogram
+			continue
+		}
+		counts[labels["state"]+"/"+key] = pb.Counter.GetValue()
+	}
+
+	wantCounts := map[string]float64{
+		"success/VirtualMachine.clone/vm":              1,
+		"error/VirtualMachine.clone/vm":                1,
+		"success/HostSystem.enterMaintenanceMode/host": 1,
+		"success/Folder.createVm/none":                 1,
+	}
+	if len(counts) != len(wantCounts) {
+		t.Errorf("got counts %v, want %v", counts, wantCounts)
+	}
+	for k, v := range wantCounts {
+		if counts[k] != v {
+			t.Errorf("%s: got %v, want %v", k, counts[k], v)
+		}
+	}
+
+	h := histograms["VirtualMachine.clone/vm"]
+	if h == nil {
+		t.Fatalf("missing duration histogram, got %v", histograms)
+	}
+	if h.GetSampleCount() != 2 || h.GetSampleSum() != 32 {
+		t.Errorf("got count %d and sum %v, want 2 and 32", h.GetSampleCount(), h.GetSampleSum())
+	}
+	if len(h.Bucket) != len(taskDurationBuckets) {
+		t.Errorf("got %d buckets, want %d", len(h.Bucket), len(taskDurationBuckets))
+	}
+	for _, b := range h.Bucket {
+		var want uint64
+		switch {
+		case b.GetUpperBound() >= 30:
+			want = 2
+		case b.GetUpperBound() >= 2:
+			want = 1
+		}
+		if b.GetCumulativeCount() != want {
+			t.Errorf("bucket %v: got %d, want %d", b.GetUpperBound(), b.GetCumulativeCount(), want)
+		}
+	}
+}
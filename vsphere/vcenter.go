@@ -0,0 +1,114 @@
+package vsphere
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+var (
+	licenseLabels = []string{"edition", "license", "cost_unit"}
+
+	vcenterInfoDesc = prometheus.NewDesc("vsphere_vcenter_info",
+		"Information about the vCenter server.",
+		[]string{"version", "build", "api_version", "instance_uuid", "os_type"}, nil)
+	vcenterClockSkewDesc = prometheus.NewDesc("vsphere_vcenter_clock_skew_seconds",
+		"Difference between the vCenter server time and the exporter time.", nil, nil)
+	vcenterSessionsDesc = prometheus.NewDesc("vsphere_vcenter_sessions",
+		"Number of active sessions on the vCenter server.", nil, nil)
+	licenseUsedDesc = prometheus.NewDesc("vsphere_license_used",
+		"Number of license units in use.", licenseLabels, nil)
+	licenseCapacityDesc = prometheus.NewDesc("vsphere_license_capacity",
+		"Number of license units available.", licenseLabels, nil)
+	licenseExpirationDesc = prometheus.NewDesc("vsphere_license_expiration_timestamp_seconds",
+		"Time the earliest license of an edition expires.", licenseLabels, nil)
+)
+
+// licenseUsage is the usage of the licenses of an edition.
+type licenseUsage struct {
+	used       float64
+	capacity   float64
+	expiration time.Time
+}
+
+// collectVCenter sends information about the vCenter server and its clock skew, along with its active sessions and
+// license usage when enabled.
+func (c *vsphereCollector) collectVCenter(ctx context.Context, metrics chan<- prometheus.Metric, cli *client,
+	skew time.Duration) {
+
+	about := cli.Client.ServiceContent.About
+	metrics <- prometheus.MustNewConstMetric(vcenterInfoDesc, prometheus.GaugeValue, 1,
+		about.Version, about.Build, about.ApiVersion, about.InstanceUuid, about.OsType)
+	metrics <- prometheus.MustNewConstMetric(vcenterClockSkewDesc, prometheus.GaugeValue, skew.Seconds())
+
+	ctx1, cancel1 := context.WithTimeout(ctx, cli.Timeout)
+	defer cancel1()
+	pc := property.DefaultCollector(cli.Client.Client)
+	if c.endpoint.cfg.Sessions && cli.Client.ServiceContent.SessionManager != nil {
+		var sm mo.SessionManager
+		err := pc.RetrieveOne(ctx1, *cli.Client.ServiceContent.SessionManager,
+			[]string{"sessionList", "currentSession"}, &sm)
+		if err != nil {
+			c.logger.Error("error getting sessions", "err", err)
+		} else if sessionListRestricted(&sm) {
+			c.logger.Warn("only the exporter session is visible, counting sessions requires the " +
+				"Sessions.TerminateSession privilege")
+		} else {
+			metrics <- prometheus.MustNewConstMetric(vcenterSessionsDesc, prometheus.GaugeValue,
+				float64(len(sm.SessionList)))
+		}
+	}
+	if c.endpoint.cfg.Licenses && cli.Client.ServiceContent.LicenseManager != nil {
+		var lm mo.LicenseManager
+		err := pc.RetrieveOne(ctx1, *cli.Client.ServiceContent.LicenseManager, []string{"licenses"}, &lm)
+		if err != nil {
+			c.logger.Error("error getting licenses", "err", err)
+			return
+		}
+		for labels, u := range licenseUsages(lm.Licenses) {
+			values := labels[:]
+			metrics <- prometheus.MustNewConstMetric(licenseUsedDesc, prometheus.GaugeValue, u.used, values...)
+			metrics <- prometheus.MustNewConstMetric(licenseCapacityDesc, prometheus.GaugeValue, u.capacity, values...)
+			if !u.expiration.IsZero() {
+				metrics <- prometheus.MustNewConstMetric(licenseExpirationDesc, prometheus.GaugeValue,
+					float64(u.expiration.Unix()), values...)
+			}
+		}
+	}
+}
+
+// sessionListRestricted reports whether the session list only holds the current session. Without the
+// Sessions.TerminateSession privilege, vCenter lists the caller's own session only, while it always has sessions of
+// its own services otherwise.
+func sessionListRestricted(sm *mo.SessionManager) bool {
+	return len(sm.SessionList) == 1 && sm.CurrentSession != nil && sm.SessionList[0].Key == sm.CurrentSession.Key
+}
+
+// licenseUsages sums the used and total units of licenses by edition, name and cost unit, and keeps the earliest
+// expiration date. Licenses without an expiration date don't expire.
+func licenseUsages(licenses []types.LicenseManagerLicenseInfo) map[[3]string]*licenseUsage {
+	usages := make(map[[3]string]*licenseUsage)
+	for _, l := range licenses {
+		key := [3]string{l.EditionKey, l.Name, l.CostUnit}
+		u, ok := usages[key]
+		if !ok {
+			u = &licenseUsage{}
+			usages[key] = u
+		}
+		u.used += float64(l.Used)
+		u.capacity += float64(l.Total)
+		for _, p := range l.Properties {
+			if p.Key != "expirationDate" {
+				continue
+			}
+			if t, ok := p.Value.(time.Time); ok && (u.expiration.IsZero() || t.Before(u.expiration)) {
+				u.expiration = t
+			}
+		}
+	}
+	return usages
+}
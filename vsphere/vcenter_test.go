@@ -0,0 +1,76 @@
+package vsphere
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+func TestLicenseUsages(t *testing.T) {
+	expires := func(t time.Time) []types.KeyAnyValue {
+		return []types.KeyAnyValue{{Key: "expirationDate", Value: t}}
+	}
+	early := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	late := time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	usages := licenseUsages([]types.LicenseManagerLicenseInfo{
+		{EditionKey: "esx.enterprisePlus.cpuPackage", Name: "vSphere 8 Enterprise Plus", CostUnit: "cpuPackage",
+			Total: 16, Used: 12, Properties: expires(late)},
+		{EditionKey: "esx.enterprisePlus.cpuPackage", Name: "vSphere 8 Enterprise Plus", CostUnit: "cpuPackage",
+			Total: 8, Used: 2, Properties: expires(early)},
+		{EditionKey: "vc.standard.instance", Name: "vCenter Server 8 Standard", CostUnit: "server",
+			Total: 1, Used: 1},
+	})
+
+	tests := []struct {
+		key  [3]string
+		want licenseUsage
+	}{
+		{
+			key:  [3]string{"esx.enterprisePlus.cpuPackage", "vSphere 8 Enterprise Plus", "cpuPackage"},
+			want: licenseUsage{used: 14, capacity: 24, expiration: early},
+		},
+		{
+			key:  [3]string{"vc.standard.instance", "vCenter Server 8 Standard", "server"},
+			want: licenseUsage{used: 1, capacity: 1},
+		},
+	}
+	if len(usages) != len(tests) {
+		t.Errorf("got %d editions, want %d", len(usages), len(tests))
+	}
+	for _, tt := range tests {
+		got := usages[tt.key]
+		if got == nil {
+			t.Errorf("%v: missing", tt.key)
+			continue
+		}
+		if *got != tt.want {
+			t.Errorf("%v: got %+v, want %+v", tt.key, *got, tt.want)
+		}
+	}
+}
+
+func TestSessionListRestricted(t *testing.T) {
+	own := types.UserSession{Key: "52b5"}
+	tests := []struct {
+		name string
+		sm   mo.SessionManager
+		want bool
+	}{
+		{"own session only", mo.SessionManager{SessionList: []types.UserSession{own}, CurrentSession: &own}, true},
+		{"all sessions", mo.SessionManager{
+			SessionList:    []types.UserSession{{Key: "vpxd-extension"}, own},
+			CurrentSession: &own,
+		}, false},
+		{"no current session", mo.SessionManager{SessionList: []types.UserSession{own}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sessionListRestricted(&tt.sm); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
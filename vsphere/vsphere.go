@@ -8,17 +8,54 @@ import (
 // vSphereConfig is the top level type for the vSphereConfig input plugin. It contains all the configuration
 // and monitors a single vSphere endpoint
 type vSphereConfig struct {
-	Username            string
-	Password            string
-	DatacenterInstances bool
-	ClusterInstances    bool
-	HostInstances       bool
-	VMInstances         bool
-	DatastoreInstances  bool
-	Separator           string
-	UseIntSamples       bool
-	IPAddresses         []string
-	MetricLookback      int
+	Username             string
+	Password             string
+	DatacenterInstances  bool
+	ClusterInstances     bool
+	HostInstances        bool
+	VMInstances          bool
+	DatastoreInstances   bool
+	Separator            string
+	UseIntSamples        bool
+	IPAddresses          []string
+	MetricLookback       int
+	IncludePaths         map[string][]string
+	ExcludePaths         map[string][]string
+	IncludeNames         map[string][]string
+	ExcludeNames         map[string][]string
+	IncludeAttributes    map[string][]string
+	ExcludeAttributes    map[string][]string
+	IncludeTags          map[string][]string
+	ExcludeTags          map[string][]string
+	VMPowerStates        []string
+	VMIncludeGuests      []string
+	VMExcludeGuests      []string
+	VMIncludeTemplates   bool
+	HostSensors          bool
+	VMSnapshotInfo       bool
+	CustomAttributes     []string
+	AttributeLabels      bool
+	TagCategories        []string
+	TagLabels            bool
+	InventoryPath        bool
+	InventoryPathLabels  bool
+	Alarms               bool
+	Tasks                bool
+	Sessions             bool
+	Licenses             bool
+	Events               bool
+	EventsInterval       time.Duration
+	EventsForward        string
+	EventsForwardTarget  string
+	EventsCheckpointFile string
+	VMKubernetesNodeKey  string
+
+	DisableDatastoreClusters bool
+	DisableResourcePools     bool
+	DisableVApps             bool
+	DisableNetworks          bool
+	DisableDVSwitches        bool
+	DisableDVPortgroups      bool
 
 	RefChunkSize            int
 	MaxQueryObjects         int
@@ -39,7 +76,9 @@ var defaultVSphere = &vSphereConfig{
 	DatastoreInstances:  false,
 	Separator:           "_",
 	UseIntSamples:       true,
-	IPAddresses:         []string{},
+	IPAddresses:         []string{"ipv4", "ipv6"},
+	IncludePaths:        defaultConfig.IncludePaths,
+	ExcludePaths:        map[string][]string{},
 
 	MaxQueryObjects:         256,
 	MaxQueryMetrics:         256,
@@ -50,4 +89,5 @@ var defaultVSphere = &vSphereConfig{
 	ObjectDiscoveryInterval: time.Second * 300,
 	Timeout:                 time.Second * 60,
 	HistoricalInterval:      time.Second * 300,
+	EventsInterval:          time.Second * 10,
 }